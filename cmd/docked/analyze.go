@@ -16,6 +16,7 @@ import (
 // AnalyzeCmd represents the analyze command
 type AnalyzeCmd struct {
 	File               string   `arg:"" optional:"" type:"path" default:"./Dockerfile" help:"Dockerfile to analyze (default: ./Dockerfile)"`
+	Context            string   `type:"path" help:"Build context directory, enabling analysis of the files brought in by COPY and ADD"`
 	NoBuildKitWarnings bool     `short:"k" help:"Suppress Docker parser warnings"`
 	Ignore             []string `short:"i" help:"Lint IDs to ignore"`
 	ReportType         string   `enum:"text,json,html" default:"text" help:"Report output type (text, json, html)"`
@@ -50,6 +51,7 @@ func (a *AnalyzeCmd) Run() error {
 	application := docked.Docked{
 		Config:                   config,
 		SuppressBuildKitWarnings: a.NoBuildKitWarnings,
+		ContextDir:               a.Context,
 	}
 
 	// Analyze
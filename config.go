@@ -6,6 +6,7 @@ import (
 	"sort"
 
 	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/rules"
 	"github.com/jimschubert/docked/model/validations"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -47,6 +48,8 @@ type Config struct {
 	SkipDefaultRules bool                          `yaml:"skip_default_rules,omitempty"`
 	// IncludeRules allows setting an approved list of rules to include when SkipDefaultRules is true
 	IncludeRules []string `yaml:"include_rules,omitempty"`
+	// Settings configures the behavior of individual rules
+	Settings rules.Settings `yaml:"settings,omitempty"`
 }
 
 // Load a Config from path with sorted members (by ID for rule overrides, by Name for custom rules)
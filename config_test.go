@@ -5,6 +5,7 @@ import (
 
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/rules"
 	"github.com/jimschubert/docked/model/validations"
 	"github.com/stretchr/testify/assert"
 )
@@ -78,6 +79,67 @@ func TestConfig_Load(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "contains rule settings",
+			args: args{"testdata/config/package_pinning.yml"},
+			want: Config{
+				Settings: rules.Settings{
+					PackagePinning: rules.PackagePinningSettings{Allow: []string{"ca-certificates", "apt-get:git"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "contains base image policy",
+			args: args{"testdata/config/base_images.yml"},
+			want: Config{
+				SkipDefaultRules: true,
+				IncludeRules:     []string{"D7:base-image-registry", "D7:base-image-digest", "D7:deprecated-base-image", "D7:base-image-platform"},
+				Settings: rules.Settings{
+					BaseImages: rules.BaseImageSettings{
+						Allow:           []string{"gcr.io/distroless", "docker.io/library"},
+						Deny:            []string{"docker.io/library/python"},
+						RequireDigest:   true,
+						Deprecated:      []rules.DeprecatedImage{{Image: "golang:<1.25", Replacement: "golang:1.25"}},
+						Supported:       []string{"centos:7"},
+						RequirePlatform: true,
+						Platforms:       []string{"linux/amd64", "linux/arm64"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "contains label schema",
+			args: args{"testdata/config/labels.yml"},
+			want: Config{
+				SkipDefaultRules: true,
+				IncludeRules:     []string{"D9:required-labels", "D9:label-value-format"},
+				Settings: rules.Settings{
+					Labels: rules.LabelSettings{
+						Required: []string{"org.opencontainers.image.source", "com.example.team"},
+						Patterns: map[string]string{"com.example.team": "^[a-z][a-z-]*$", "com.example.*": `^\S+$`},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "contains expose policy",
+			args: args{"testdata/config/expose.yml"},
+			want: Config{
+				SkipDefaultRules: true,
+				IncludeRules:     []string{"D6:questionable-expose", "D6:expose-required-ports"},
+				Settings: rules.Settings{
+					Expose: rules.ExposeSettings{
+						Deny:     []string{"22", "2000-2999/tcp"},
+						Allow:    []string{"8000-9999"},
+						Required: []string{"8000-8099"},
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -13,6 +13,7 @@ import (
 	"github.com/jimschubert/docked/model/docker"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/rules"
+	"github.com/jimschubert/docked/model/secrets"
 	"github.com/jimschubert/docked/model/validations"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 	log "github.com/sirupsen/logrus"
@@ -24,7 +25,9 @@ type Docked struct {
 	Config Config
 	// Suppress the underlying warnings presented by buildkit's parser. Use this if you want to pipe text summary to file.
 	SuppressBuildKitWarnings bool
-	rulePriorityOverrides    *map[string]model.Priority
+	// ContextDir is the build context directory. When set, rules may inspect the files which COPY and ADD bring into the image.
+	ContextDir            string
+	rulePriorityOverrides *map[string]model.Priority
 }
 
 // AnalysisResult holds final validations, separated in those which have been Evaluated and those which have not (NotEvaluated).
@@ -81,14 +84,30 @@ func (d *Docked) AnalyzeWithRuleList(location string, configuredRules Configured
 		log.Fatal("Could not determine absolute path to Dockerfile")
 	}
 
-	dockerfile, err := os.Open(fullPath)
+	dockerfile, err := os.ReadFile(fullPath)
 	if err != nil {
 		log.Fatal("Could not open path")
 	}
-	p, err := parser.Parse(dockerfile)
+	p, err := parser.Parse(bytes.NewReader(dockerfile))
 	if err != nil || p == nil {
 		log.Fatal("Could not parse Dockerfile")
 	}
+	directives := docker.ParseDirectives(dockerfile)
+	lines := strings.Split(string(dockerfile), "\n")
+
+	var buildContext *docker.BuildContext
+	if d.ContextDir != "" {
+		if buildContext, err = docker.NewBuildContext(d.ContextDir); err != nil {
+			log.Warnf("Unable to use build context %s, skipping context analysis: %s", d.ContextDir, err)
+		}
+	}
+	dockerIgnore, err := docker.FindDockerIgnore(fullPath, d.ContextDir)
+	if err != nil {
+		log.Warnf("Unable to read .dockerignore, skipping: %s", err)
+	}
+	if buildContext != nil {
+		buildContext.Ignore = dockerIgnore
+	}
 
 	validationsRan := make([]validations.Validation, 0)
 	validationsNotRan := make([]validations.Validation, 0)
@@ -103,19 +122,61 @@ func (d *Docked) AnalyzeWithRuleList(location string, configuredRules Configured
 	seenCommands := make(map[commands.DockerCommand]bool)
 
 	finalStage := d.finalStageIndex(p.AST.Children)
+	shells := stageShells{}
+	variables := stageVariables{escapeToken: directives.EscapeToken()}
+	buildStages := docker.BuildStages(p.AST.Children)
+	var stage *docker.Stage
+	nextStage := 0
 
-	//goland:noinspection ALL
-	for idx, node := range p.AST.Children {
+	evaluate := func(node *parser.Node, baseContext validations.ValidationContext) {
 		thisCommand := commands.Of(node.Value)
 		seenCommands[thisCommand] = true
 		if commandRules, ok := configuredRules.Active[thisCommand]; ok {
 			if commandRules == nil {
 				log.Warnf("Active rule mapped 0 rules to command %s", thisCommand)
-				continue
+				return
 			}
 			currentRules := *commandRules
-			isBuilderStage := idx < finalStage
-			d.evaluateNode(node, isBuilderStage, &currentRules, &validationsRan, &validationsNotRan, &deferredEvaluationRules, fullPath)
+			d.evaluateNode(node, baseContext, &currentRules, &validationsRan, &validationsNotRan, &deferredEvaluationRules, fullPath)
+		}
+	}
+
+	//goland:noinspection ALL
+	for idx, node := range p.AST.Children {
+		parsed := docker.ParseInstruction(node)
+		if parsed.Err != nil {
+			log.Debugf("Unable to parse %s instruction at line %d: %s", parsed.Command.Upper(), node.StartLine, parsed.Err)
+		}
+		shells.track(parsed)
+		if parsed.From() != nil {
+			stage = buildStages[nextStage]
+			nextStage++
+		}
+		activeVariables := variables.active
+		variables.track(parsed)
+		baseContext := validations.ValidationContext{
+			IsBuilderContext: idx < finalStage,
+			Shell:            shells.active,
+			Instruction:      parsed,
+			Stage:            stage,
+			Variables:        activeVariables,
+			Directives:       directives,
+			Source:           docker.NewSource(lines, node),
+			BuildContext:     buildContext,
+			DockerIgnore:     dockerIgnore,
+			Settings:         &d.Config.Settings,
+		}
+		evaluate(node, baseContext)
+
+		// ONBUILD triggers are evaluated by the rules of the wrapped instruction which opt in via AppliesToOnbuild
+		if trigger := docker.OnbuildTrigger(node); trigger != nil && !docker.IsForbiddenOnbuildTrigger(commands.Of(trigger.Value)) {
+			triggerContext := baseContext
+			triggerContext.IsOnbuildTrigger = true
+			triggerContext.Instruction = docker.ParseInstruction(trigger)
+			// triggers copy from the build context of downstream builds
+			triggerContext.BuildContext = nil
+			triggerContext.DockerIgnore = nil
+			evaluate(trigger, triggerContext)
 		}
 	}
 
@@ -165,6 +226,8 @@ func (d *Docked) AnalyzeWithRuleList(location string, configuredRules Configured
 		}
 	}
 
+	redactSecrets(lines, validationsRan, validationsNotRan)
+
 	// Ensure returned lists are in consistent orders
 	sort.Slice(validationsRan, func(left, right int) bool {
 		return validationsRan[left].ID < validationsRan[right].ID
@@ -176,6 +239,46 @@ func (d *Docked) AnalyzeWithRuleList(location string, configuredRules Configured
 	return AnalysisResult{Evaluated: validationsRan, NotEvaluated: validationsNotRan}, nil
 }
 
+// redactSecrets masks each secret located by a sensitive context within the details and lines of all results,
+// so reporters never output a secret even when another rule includes the instruction's text.
+func redactSecrets(lines []string, results ...[]validations.Validation) {
+	replacements := make([]string, 0)
+	for _, validationResults := range results {
+		for _, validation := range validationResults {
+			for _, context := range validation.Contexts {
+				if !context.Sensitive {
+					continue
+				}
+				for _, location := range context.Locations {
+					if location.Start.Line != location.End.Line || location.Start.Line < 1 || location.Start.Line > len(lines) {
+						continue
+					}
+					line := lines[location.Start.Line-1]
+					if location.Start.Character < 0 || location.End.Character > len(line) || location.Start.Character >= location.End.Character {
+						continue
+					}
+					value := line[location.Start.Character:location.End.Character]
+					replacements = append(replacements, value, secrets.Mask(value))
+				}
+			}
+		}
+	}
+	if len(replacements) == 0 {
+		return
+	}
+
+	replacer := strings.NewReplacer(replacements...)
+	for _, validationResults := range results {
+		for i := range validationResults {
+			validation := &validationResults[i]
+			validation.Details = replacer.Replace(validation.Details)
+			for j := range validation.Contexts {
+				validation.Contexts[j].Line = replacer.Replace(validation.Contexts[j].Line)
+			}
+		}
+	}
+}
+
 // Analyze a dockerfile residing at location.
 //
 // All known rules which are applicable to the Dockerfile contents are evaluated,
@@ -201,10 +304,74 @@ func (d Docked) finalStageIndex(nodes []*parser.Node) int {
 	return finalStageAt
 }
 
+// stageShells tracks the SHELL instruction active in the current build stage. Stages built from a named stage inherit its shell.
+type stageShells struct {
+	active []string
+	stage  string
+	named  map[string][]string
+}
+
+// track updates the active shell for FROM and SHELL instructions
+func (s *stageShells) track(parsed *docker.ParsedInstruction) {
+	if s.named == nil {
+		s.named = make(map[string][]string)
+	}
+
+	if stage := parsed.From(); stage != nil {
+		s.active = s.named[strings.ToLower(stage.BaseName)]
+		s.stage = stage.Name
+	} else if shell := parsed.Shell(); shell != nil {
+		s.active = shell.Shell
+	} else {
+		return
+	}
+
+	if s.stage != "" {
+		s.named[s.stage] = s.active
+	}
+}
+
+// stageVariables tracks the values of ARG and ENV in effect in the current build stage. Stages see the ARGs declared before the
+// first FROM only when redeclared, and stages built from a named stage inherit its variables.
+type stageVariables struct {
+	active      docker.Variables
+	meta        docker.Variables
+	escapeToken rune
+	inStage     bool
+	stage       string
+	named       map[string]docker.Variables
+}
+
+// track updates the active variables for FROM, ARG and ENV instructions
+func (s *stageVariables) track(parsed *docker.ParsedInstruction) {
+	if s.named == nil {
+		s.named = make(map[string]docker.Variables)
+	}
+
+	if stage := parsed.From(); stage != nil {
+		s.active = s.named[strings.ToLower(stage.BaseName)]
+		s.stage = strings.ToLower(stage.Name)
+		s.inStage = true
+	} else if parsed.Arg() != nil || parsed.Env() != nil {
+		if s.inStage {
+			s.active = s.active.With(parsed, s.meta, s.escapeToken)
+		} else {
+			s.active = s.active.With(parsed, nil, s.escapeToken)
+			s.meta = s.active
+		}
+	} else {
+		return
+	}
+
+	if s.stage != "" {
+		s.named[s.stage] = s.active
+	}
+}
+
 // evaluateNode invokes rule evaluation. It determines whether the evaluated rule should be deferred, and partitions into ran/notRan collections.
 func (d *Docked) evaluateNode(
 	node *parser.Node,
-	isBuilderStage bool,
+	baseContext validations.ValidationContext,
 	commandRules *[]validations.Rule,
 	validationsRan *[]validations.Validation,
 	validationsNotRan *[]validations.Validation,
@@ -215,11 +382,9 @@ func (d *Docked) evaluateNode(
 	for _, rule := range evaluating {
 		ruleID := rule.GetLintID()
 		locations := docker.FromParserRanges(node.Location())
-		validationContext := validations.ValidationContext{
-			Line:             node.Original,
-			Locations:        locations,
-			IsBuilderContext: isBuilderStage,
-		}
+		validationContext := baseContext
+		validationContext.Line = node.Original
+		validationContext.Locations = locations
 
 		result := rule.Evaluate(node, validationContext)
 		if finalizer, ok := rule.(validations.FinalizingRule); ok {
@@ -292,8 +457,10 @@ func printRulesSkipped(v validations.Validation) {
 
 // buildConfiguredRules evaluates which rules to ignore via config, and splits all known rules into active and inactive collections, exposed as ConfiguredRules
 func buildConfiguredRules(config Config) ConfiguredRules {
+
 	ignoreLookup := make(map[string]bool)
 	includeLookup := make(map[string]bool)
+	activeLookup := make(map[string]bool)
 	for _, ignore := range config.Ignore {
 		ignoreLookup[ignore] = true
 	}
@@ -318,7 +485,11 @@ func buildConfiguredRules(config Config) ConfiguredRules {
 					resettable.Reset()
 				}
 				if !config.SkipDefaultRules {
-					activeRules.AddRule(rule)
+					// multi-command rules are listed under each of their commands, but must only be added once
+					if !activeLookup[ruleID] {
+						activeRules.AddRule(rule)
+						activeLookup[ruleID] = true
+					}
 				} else if includeLookup[ruleID] {
 					activeRules.AddRule(rule)
 					// only need to account for multi-command rules once
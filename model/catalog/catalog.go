@@ -0,0 +1,186 @@
+// Package catalog provides facts about popular base images, such as the distribution, default user, package managers,
+// and shell, allowing rules to evaluate instructions against the base image of a stage.
+//
+// The built-in catalog is embedded from images.yaml. Additional entries may be loaded from an override file of the same format.
+package catalog
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model/docker"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed images.yaml
+var embeddedCatalog []byte
+
+// Patterns is a list of patterns compatible with path.Match, which may be written in YAML as a single value or a list
+type Patterns []string
+
+// UnmarshalYAML accepts a single pattern or a list of patterns
+func (p *Patterns) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = Patterns{value.Value}
+		return nil
+	}
+	var patterns []string
+	if err := value.Decode(&patterns); err != nil {
+		return err
+	}
+	*p = patterns
+	return nil
+}
+
+// matches determines whether s matches any of the patterns
+func (p Patterns) matches(s string) bool {
+	for _, pattern := range p {
+		if matched, _ := path.Match(pattern, s); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageFacts describes a base image
+type ImageFacts struct {
+	// Repository matches the familiar names of the images (e.g. python or gcr.io/distroless/*)
+	Repository Patterns `yaml:"repository"`
+	// Tags matches the tags of the images, or all tags if empty. An image without a tag is latest.
+	Tags Patterns `yaml:"tags,omitempty"`
+	// Distro is the distribution family, e.g. alpine, debian, or distroless
+	Distro string `yaml:"distro"`
+	// User is the default user, root if empty
+	User string `yaml:"user,omitempty"`
+	// PackageManagers are the OS package managers available in the image
+	PackageManagers []string `yaml:"package_managers,omitempty"`
+	// Shell is the path of the shell included in the image, or empty if the image has no shell
+	Shell string `yaml:"shell,omitempty"`
+	// Env matches the names of the variables set by ENV in the image, other than PATH
+	Env Patterns `yaml:"env,omitempty"`
+	// Tools are the HTTP clients (curl, wget) included in the image, or nil if unknown. Empty when the image includes neither.
+	Tools []string `yaml:"tools,omitempty"`
+}
+
+// DefaultShell is the shell required by shell-form instructions, unless another is selected via SHELL
+const DefaultShell = "/bin/sh"
+
+// IsRoot determines whether the image runs as root by default
+func (f ImageFacts) IsRoot() bool {
+	return f.User == "" || f.User == "root" || f.User == "0"
+}
+
+// HasShell determines whether the image includes a shell
+func (f ImageFacts) HasShell() bool {
+	return f.Shell != ""
+}
+
+// HasPackageManager determines whether the package manager is available in the image
+func (f ImageFacts) HasPackageManager(name string) bool {
+	for _, manager := range f.PackageManagers {
+		if manager == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DefinesEnv determines whether the image sets the variable via ENV
+func (f ImageFacts) DefinesEnv(name string) bool {
+	return name == "PATH" || f.Env.matches(name)
+}
+
+// HasTool determines whether the image includes the tool, and whether the tools of the image are known
+func (f ImageFacts) HasTool(name string) (included bool, known bool) {
+	if f.Tools == nil {
+		return false, false
+	}
+	for _, tool := range f.Tools {
+		if tool == name {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// matches determines whether the entry describes ref
+func (f ImageFacts) matches(ref docker.ImageReference) bool {
+	if !f.Repository.matches(ref.Name()) {
+		return false
+	}
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return len(f.Tags) == 0 || f.Tags.matches(tag)
+}
+
+// Catalog is an ordered list of ImageFacts, where the first matching entry describes an image
+type Catalog struct {
+	Images []ImageFacts `yaml:"images"`
+}
+
+// Parse reads a catalog from YAML
+func Parse(b []byte) (*Catalog, error) {
+	c := Catalog{}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	for i, image := range c.Images {
+		if len(image.Repository) == 0 {
+			return nil, fmt.Errorf("image %d of the catalog has no repository", i)
+		}
+	}
+	return &c, nil
+}
+
+// Load reads a catalog from the YAML file at path
+func Load(path string) (*Catalog, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Default returns the built-in catalog
+func Default() *Catalog {
+	c, err := Parse(embeddedCatalog)
+	if err != nil {
+		// the embedded catalog is validated by tests
+		panic(err)
+	}
+	return c
+}
+
+// WithOverrides returns a catalog in which the entries of overrides take precedence over those of c
+func (c *Catalog) WithOverrides(overrides *Catalog) *Catalog {
+	if overrides == nil {
+		return c
+	}
+	images := make([]ImageFacts, 0, len(overrides.Images)+len(c.Images))
+	images = append(images, overrides.Images...)
+	images = append(images, c.Images...)
+	return &Catalog{Images: images}
+}
+
+// Lookup finds the facts of image, a reference as written in FROM. Returns false for unknown images, including
+// those which can't be resolved without build arguments.
+func (c *Catalog) Lookup(image string) (ImageFacts, bool) {
+	if c == nil || strings.Contains(image, "$") {
+		return ImageFacts{}, false
+	}
+	ref, err := docker.ParseImageReference(image)
+	if err != nil {
+		return ImageFacts{}, false
+	}
+	for _, facts := range c.Images {
+		if facts.matches(ref) {
+			return facts, true
+		}
+	}
+	return ImageFacts{}, false
+}
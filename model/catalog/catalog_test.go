@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	tests := []struct {
+		image          string
+		wantDistro     string
+		wantShell      bool
+		wantRoot       bool
+		packageManager string
+	}{
+		{image: "alpine:3.20", wantDistro: "alpine", wantShell: true, wantRoot: true, packageManager: "apk"},
+		{image: "docker.io/library/debian:bookworm-slim", wantDistro: "debian", wantShell: true, wantRoot: true, packageManager: "apt-get"},
+		{image: "node:20-alpine3.19", wantDistro: "alpine", wantShell: true, wantRoot: true, packageManager: "apk"},
+		{image: "node:20", wantDistro: "debian", wantShell: true, wantRoot: true, packageManager: "apt-get"},
+		{image: "python", wantDistro: "debian", wantShell: true, wantRoot: true, packageManager: "apt-get"},
+		{image: "gcr.io/distroless/static-debian12:nonroot", wantDistro: "distroless", wantShell: false, wantRoot: false},
+		{image: "gcr.io/distroless/base:debug", wantDistro: "distroless", wantShell: true, wantRoot: true},
+		{image: "gcr.io/distroless/base@sha256:abc123", wantDistro: "distroless", wantShell: false, wantRoot: true},
+		{image: "scratch", wantDistro: "scratch", wantShell: false, wantRoot: true},
+		{image: "rockylinux:9-minimal", wantDistro: "rhel", wantShell: true, wantRoot: true, packageManager: "microdnf"},
+		{image: "amazonlinux:2023", wantDistro: "amazonlinux", wantShell: true, wantRoot: true, packageManager: "dnf"},
+		{image: "bitnami/nginx:1.25", wantDistro: "debian", wantShell: true, wantRoot: false, packageManager: "apt-get"},
+	}
+	catalog := Default()
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			facts, ok := catalog.Lookup(tt.image)
+			if !ok {
+				t.Fatalf("Lookup(%s) not found", tt.image)
+			}
+			if facts.Distro != tt.wantDistro {
+				t.Errorf("Distro = %s, want %s", facts.Distro, tt.wantDistro)
+			}
+			if facts.HasShell() != tt.wantShell {
+				t.Errorf("HasShell() = %v, want %v", facts.HasShell(), tt.wantShell)
+			}
+			if facts.IsRoot() != tt.wantRoot {
+				t.Errorf("IsRoot() = %v, want %v", facts.IsRoot(), tt.wantRoot)
+			}
+			if tt.packageManager != "" && !facts.HasPackageManager(tt.packageManager) {
+				t.Errorf("HasPackageManager(%s) = false, want true", tt.packageManager)
+			}
+		})
+	}
+}
+
+func TestCatalog_Lookup_unknown(t *testing.T) {
+	for _, image := range []string{"example/unknown:1.0", "${BASE_IMAGE}", "$BASE", ""} {
+		if facts, ok := Default().Lookup(image); ok {
+			t.Errorf("Lookup(%s) = %#v, want not found", image, facts)
+		}
+	}
+}
+
+func TestCatalog_WithOverrides(t *testing.T) {
+	overrides, err := Load("../../testdata/catalog/overrides.yaml")
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	catalog := Default().WithOverrides(overrides)
+
+	tests := []struct {
+		image      string
+		wantDistro string
+		wantShell  string
+	}{
+		{image: "registry.example.com/base/java:21-minimal", wantDistro: "distroless"},
+		{image: "registry.example.com/base/java:21", wantDistro: "alpine", wantShell: "/bin/sh"},
+		{image: "python:3.12-custom", wantDistro: "alpine", wantShell: "/bin/ash"},
+		{image: "python:3.12", wantDistro: "debian", wantShell: "/bin/sh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			facts, ok := catalog.Lookup(tt.image)
+			if !ok {
+				t.Fatalf("Lookup(%s) not found", tt.image)
+			}
+			if facts.Distro != tt.wantDistro || facts.Shell != tt.wantShell {
+				t.Errorf("Lookup(%s) = %#v, want distro %s and shell %q", tt.image, facts, tt.wantDistro, tt.wantShell)
+			}
+		})
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	if _, err := Parse([]byte("images:\n  - distro: alpine\n")); err == nil {
+		t.Error("Parse() error = nil, want an error for an entry without repository")
+	}
+	if _, err := Parse([]byte("images: [")); err == nil {
+		t.Error("Parse() error = nil, want an error for invalid YAML")
+	}
+}
+
+func TestImageFacts_DefinesEnv(t *testing.T) {
+	tests := []struct {
+		image string
+		name  string
+		want  bool
+	}{
+		{image: "golang:1.22", name: "GOPATH", want: true},
+		{image: "golang:1.22-alpine", name: "GOPATH", want: true},
+		{image: "eclipse-temurin:21-jre", name: "JAVA_HOME", want: true},
+		{image: "php:8.3-fpm", name: "PHP_INI_DIR", want: true},
+		{image: "bitnami/nginx:1.25", name: "NGINX_VERSION", want: true},
+		{image: "alpine:3.20", name: "PATH", want: true},
+		{image: "alpine:3.20", name: "GOPATH", want: false},
+		{image: "node:20", name: "JAVA_HOME", want: false},
+	}
+	catalog := Default()
+	for _, tt := range tests {
+		t.Run(tt.image+" "+tt.name, func(t *testing.T) {
+			facts, ok := catalog.Lookup(tt.image)
+			if !ok {
+				t.Fatalf("Lookup(%s) not found", tt.image)
+			}
+			if got := facts.DefinesEnv(tt.name); got != tt.want {
+				t.Errorf("DefinesEnv(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageFacts_HasTool(t *testing.T) {
+	tests := []struct {
+		image        string
+		tool         string
+		wantIncluded bool
+		wantKnown    bool
+	}{
+		{image: "python:3.12", tool: "curl", wantIncluded: true, wantKnown: true},
+		{image: "python:3.12-slim", tool: "curl", wantIncluded: false, wantKnown: true},
+		{image: "node:20-alpine", tool: "wget", wantIncluded: true, wantKnown: true},
+		{image: "debian:bookworm-slim", tool: "wget", wantIncluded: false, wantKnown: true},
+		{image: "gcr.io/distroless/static:nonroot", tool: "curl", wantIncluded: false, wantKnown: true},
+		{image: "nginx:1.27", tool: "curl", wantIncluded: false, wantKnown: false},
+	}
+	catalog := Default()
+	for _, tt := range tests {
+		t.Run(tt.image+" "+tt.tool, func(t *testing.T) {
+			facts, ok := catalog.Lookup(tt.image)
+			if !ok {
+				t.Fatalf("Lookup(%s) not found", tt.image)
+			}
+			if included, known := facts.HasTool(tt.tool); included != tt.wantIncluded || known != tt.wantKnown {
+				t.Errorf("HasTool(%s) = %v, %v, want %v, %v", tt.tool, included, known, tt.wantIncluded, tt.wantKnown)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BuildContext is the directory of files sent to the builder, which COPY and ADD sources are relative to
+type BuildContext struct {
+	// Dir is the absolute path of the context directory
+	Dir string
+	// Ignore excludes files from the context, or is nil if the context has no ignore file
+	Ignore *DockerIgnore
+}
+
+// NewBuildContext creates a BuildContext for dir, which must be an existing directory
+func NewBuildContext(dir string) (*BuildContext, error) {
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absolute)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "context", Path: dir, Err: fs.ErrInvalid}
+	}
+	return &BuildContext{Dir: absolute}, nil
+}
+
+// Files expands a COPY or ADD source (a path or glob pattern) against the context directory. Directories are walked,
+// returning both the directories and the files within them. Files excluded by Ignore are omitted.
+// Returns slash-separated paths relative to the context directory, in lexical order.
+func (c *BuildContext) Files(source string) []string {
+	if c == nil {
+		return nil
+	}
+	pattern := filepath.Join(c.Dir, filepath.FromSlash(strings.TrimPrefix(source, "/")))
+	if rel, err := filepath.Rel(c.Dir, pattern); err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	files := make([]string, 0)
+	for _, match := range matches {
+		_ = filepath.WalkDir(match, func(path string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel, err := filepath.Rel(c.Dir, path)
+			if err != nil || rel == "." || seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			if c.Ignore.Ignores(filepath.ToSlash(rel)) {
+				// children are still walked, as a later exclusion (e.g. !dir/keep) may include them
+				return nil
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,55 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildContext_Files(t *testing.T) {
+	buildContext, err := NewBuildContext("../../testdata/copy_sensitive/context")
+	if err != nil {
+		t.Fatalf("NewBuildContext error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{name: "file", source: "config/app.yaml", want: []string{"config/app.yaml"}},
+		{name: "directory", source: "config/", want: []string{"config", "config/app.yaml", "config/credentials.json"}},
+		{name: "glob", source: "src/*.go", want: []string{"src/main.go"}},
+		{name: "absolute", source: "/certs", want: []string{"certs", "certs/server.pem"}},
+		{name: "missing", source: "missing.txt", want: []string{}},
+		{name: "outside of context", source: "../static.dockerfile", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildContext.Files(tt.source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Files(%q) = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBuildContext(t *testing.T) {
+	if _, err := NewBuildContext("../../testdata/copy_sensitive/static.dockerfile"); err == nil {
+		t.Errorf("NewBuildContext should fail for a file")
+	}
+	if _, err := NewBuildContext("../../testdata/missing"); err == nil {
+		t.Errorf("NewBuildContext should fail for a missing directory")
+	}
+}
+
+func TestBuildContext_Files_ignored(t *testing.T) {
+	buildContext, err := NewBuildContext("../../testdata/dockerignore/context")
+	if err != nil {
+		t.Fatalf("NewBuildContext error = %v", err)
+	}
+	if buildContext.Ignore, err = ReadDockerIgnore("../../testdata/dockerignore/context/.dockerignore"); err != nil {
+		t.Fatalf("ReadDockerIgnore error = %v", err)
+	}
+	want := []string{".dockerignore", "certs", "certs/server.pem", "src", "src/main.go"}
+	if got := buildContext.Files("."); !reflect.DeepEqual(got, want) {
+		t.Errorf("Files(\".\") = %v, want %v", got, want)
+	}
+}
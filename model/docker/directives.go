@@ -0,0 +1,205 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// dockerfileFrontend is the repository of Docker's official Dockerfile frontend images
+const dockerfileFrontend = "docker/dockerfile"
+
+var (
+	syntaxVersionPattern = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?(-labs)?$`)
+	directiveLikePattern = regexp.MustCompile(`(?i)^\s*#\s*(syntax|escape|check)\s*=\s*(.*?)\s*$`)
+)
+
+// Feature is a Dockerfile syntax feature which requires a minimum version of the docker/dockerfile frontend
+type Feature struct {
+	// Name of the feature, for display
+	Name string
+	// Minor is the minimum docker/dockerfile:1.x release which supports the feature in stable syntax
+	Minor int
+}
+
+//goland:noinspection ALL
+var (
+	FeatureRunMount    = Feature{Name: "RUN --mount", Minor: 2}
+	FeatureRunNetwork  = Feature{Name: "RUN --network", Minor: 3}
+	FeatureRunSecurity = Feature{Name: "RUN --security", Minor: 20}
+	FeatureChmod       = Feature{Name: "ADD/COPY --chmod", Minor: 2}
+	FeatureCopyLink    = Feature{Name: "ADD/COPY --link", Minor: 4}
+	FeatureCopyParents = Feature{Name: "COPY --parents", Minor: 20}
+	FeatureCopyExclude = Feature{Name: "ADD/COPY --exclude", Minor: 19}
+	FeatureHeredoc     = Feature{Name: "heredocs", Minor: 4}
+	FeatureAddChecksum = Feature{Name: "ADD --checksum", Minor: 6}
+)
+
+// Directives are the parser directives defined at the top of a Dockerfile (e.g. # syntax=docker/dockerfile:1.4)
+type Directives struct {
+	// Syntax is the frontend image of the syntax directive, if defined
+	Syntax *Syntax `json:"syntax,omitempty"`
+	// Escape is the escape directive, if defined
+	Escape *Directive `json:"escape,omitempty"`
+	// Check is the check directive configuring buildkit's build checks, if defined
+	Check *Directive `json:"check,omitempty"`
+	// Misplaced holds comments which look like directives, but are ignored by the builder because they follow
+	// an instruction, comment, or blank line
+	Misplaced []Directive `json:"misplaced,omitempty"`
+}
+
+// Directive is a single parser directive, such as # escape=`
+type Directive struct {
+	// Name of the directive, lower-cased
+	Name string `json:"name"`
+	// Value of the directive
+	Value string `json:"value"`
+	// Original is the full line of text defining the directive
+	Original string `json:"original"`
+	// Locations of the directive within the Dockerfile
+	Locations []Location `json:"locations,omitempty"`
+}
+
+// Syntax is the frontend image reference of a syntax directive
+type Syntax struct {
+	// Image is the full image reference, e.g. docker/dockerfile:1.4@sha256:…
+	Image string `json:"image"`
+	// Repository is the image reference without tag or digest, and without the default docker.io registry
+	Repository string `json:"repository"`
+	// Tag of the frontend image, if any
+	Tag string `json:"tag,omitempty"`
+	// Digest of the frontend image, if any
+	Digest string `json:"digest,omitempty"`
+	// Locations of the directive within the Dockerfile
+	Locations []Location `json:"locations,omitempty"`
+}
+
+// ParseDirectives reads the parser directives from the contents of a Dockerfile
+func ParseDirectives(dt []byte) *Directives {
+	directives := &Directives{}
+	if image, _, ranges, ok := parser.DetectSyntax(dt); ok {
+		directives.Syntax = NewSyntax(image)
+		directives.Syntax.Locations = FromParserRanges(ranges)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(dt, []byte{0xEF, 0xBB, 0xBF})))
+	directiveParser := parser.DirectiveParser{}
+	inHeader := true
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 && strings.HasPrefix(text, "#!") {
+			// buildkit ignores a leading shebang when detecting directives
+			continue
+		}
+		location := []Location{{Start: Position{Line: line}, End: Position{Line: line}}}
+
+		if inHeader {
+			// directiveParser tracks its own line numbers, which don't account for a shebang; only name and value are used.
+			// Duplicate directives are an error, which buildkit's parser reports before analysis.
+			directive, err := directiveParser.ParseLine(scanner.Bytes())
+			if err != nil {
+				continue
+			}
+			if directive != nil {
+				d := &Directive{Name: directive.Name, Value: directive.Value, Original: text, Locations: location}
+				switch directive.Name {
+				case "escape":
+					directives.Escape = d
+				case "check":
+					directives.Check = d
+				}
+				continue
+			}
+			inHeader = false
+		}
+
+		if match := directiveLikePattern.FindStringSubmatch(text); match != nil {
+			directives.Misplaced = append(directives.Misplaced, Directive{Name: strings.ToLower(match[1]), Value: match[2], Original: text, Locations: location})
+		}
+	}
+	return directives
+}
+
+// EscapeToken is the character used to escape characters and continue lines, \ unless changed via the escape directive
+func (d *Directives) EscapeToken() rune {
+	if d == nil || d.Escape == nil || d.Escape.Value != "`" {
+		return '\\'
+	}
+	return '`'
+}
+
+// NewSyntax parses the image reference of a syntax directive
+func NewSyntax(image string) *Syntax {
+	s := &Syntax{Image: image}
+	ref := image
+	if at := strings.Index(ref, "@"); at >= 0 {
+		s.Digest = ref[at+1:]
+		ref = ref[:at]
+	}
+	if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		s.Tag = ref[colon+1:]
+		ref = ref[:colon]
+	}
+	s.Repository = strings.TrimPrefix(ref, "docker.io/")
+	return s
+}
+
+// IsDockerfileFrontend determines whether the syntax refers to Docker's official docker/dockerfile frontend
+func (s *Syntax) IsDockerfileFrontend() bool {
+	return s != nil && s.Repository == dockerfileFrontend
+}
+
+// Version parses the tag of the docker/dockerfile frontend into major, minor, and patch components.
+// Components which are not specified (such as minor in docker/dockerfile:1) are -1, as the tag floats across releases.
+// The final return value is false when the tag isn't a version (e.g. latest, labs, or a digest only).
+func (s *Syntax) Version() (major int, minor int, patch int, ok bool) {
+	if s == nil {
+		return -1, -1, -1, false
+	}
+	match := syntaxVersionPattern.FindStringSubmatch(s.Tag)
+	if match == nil {
+		return -1, -1, -1, false
+	}
+	component := func(v string) int {
+		if v == "" {
+			return -1
+		}
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return component(match[1]), component(match[2]), component(match[3]), true
+}
+
+// IsLabs determines whether the syntax refers to a labs channel of the frontend, which includes experimental features
+func (s *Syntax) IsLabs() bool {
+	return s != nil && (s.Tag == "labs" || strings.HasSuffix(s.Tag, "-labs"))
+}
+
+// Supports determines whether a Feature is available according to the syntax directive.
+// Without a syntax directive, the builder's embedded frontend is used and features are assumed available.
+// Custom frontends, floating tags and labs channels are also assumed to support the feature.
+func (d *Directives) Supports(feature Feature) bool {
+	if d == nil || d.Syntax == nil || !d.Syntax.IsDockerfileFrontend() || d.Syntax.IsLabs() {
+		return true
+	}
+	major, minor, _, ok := d.Syntax.Version()
+	if !ok || major > 1 || minor < 0 {
+		return true
+	}
+	return major == 1 && minor >= feature.Minor
+}
+
+// Unsupported filters features to those which are unavailable according to the syntax directive
+func (d *Directives) Unsupported(features []Feature) []Feature {
+	unsupported := make([]Feature, 0)
+	for _, feature := range features {
+		if !d.Supports(feature) {
+			unsupported = append(unsupported, feature)
+		}
+	}
+	return unsupported
+}
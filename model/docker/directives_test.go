@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewSyntax(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		want  Syntax
+	}{
+		{
+			name:  "tagged",
+			image: "docker/dockerfile:1.4",
+			want:  Syntax{Image: "docker/dockerfile:1.4", Repository: "docker/dockerfile", Tag: "1.4"},
+		},
+		{
+			name:  "default registry",
+			image: "docker.io/docker/dockerfile:1",
+			want:  Syntax{Image: "docker.io/docker/dockerfile:1", Repository: "docker/dockerfile", Tag: "1"},
+		},
+		{
+			name:  "tag and digest",
+			image: "docker/dockerfile:1.7@sha256:abc123",
+			want:  Syntax{Image: "docker/dockerfile:1.7@sha256:abc123", Repository: "docker/dockerfile", Tag: "1.7", Digest: "sha256:abc123"},
+		},
+		{
+			name:  "registry with port",
+			image: "localhost:5000/frontend",
+			want:  Syntax{Image: "localhost:5000/frontend", Repository: "localhost:5000/frontend"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSyntax(tt.image); !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("NewSyntax() = %#v, want %#v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyntax_Version(t *testing.T) {
+	tests := []struct {
+		tag       string
+		wantMajor int
+		wantMinor int
+		wantPatch int
+		wantOk    bool
+	}{
+		{tag: "1", wantMajor: 1, wantMinor: -1, wantPatch: -1, wantOk: true},
+		{tag: "1.4", wantMajor: 1, wantMinor: 4, wantPatch: -1, wantOk: true},
+		{tag: "1.4.3", wantMajor: 1, wantMinor: 4, wantPatch: 3, wantOk: true},
+		{tag: "1.7-labs", wantMajor: 1, wantMinor: 7, wantPatch: -1, wantOk: true},
+		{tag: "latest", wantMajor: -1, wantMinor: -1, wantPatch: -1, wantOk: false},
+		{tag: "", wantMajor: -1, wantMinor: -1, wantPatch: -1, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			major, minor, patch, ok := (&Syntax{Tag: tt.tag}).Version()
+			if major != tt.wantMajor || minor != tt.wantMinor || patch != tt.wantPatch || ok != tt.wantOk {
+				t.Errorf("Version() = %d, %d, %d, %v, want %d, %d, %d, %v", major, minor, patch, ok, tt.wantMajor, tt.wantMinor, tt.wantPatch, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestDirectives_Supports(t *testing.T) {
+	tests := []struct {
+		name       string
+		directives *Directives
+		feature    Feature
+		want       bool
+	}{
+		{name: "nil directives", directives: nil, feature: FeatureCopyLink, want: true},
+		{name: "no syntax", directives: &Directives{}, feature: FeatureCopyLink, want: true},
+		{name: "floating major", directives: ParseDirectives([]byte("# syntax=docker/dockerfile:1\nFROM scratch")), feature: FeatureCopyParents, want: true},
+		{name: "pinned supported", directives: ParseDirectives([]byte("# syntax=docker/dockerfile:1.4\nFROM scratch")), feature: FeatureCopyLink, want: true},
+		{name: "pinned unsupported", directives: ParseDirectives([]byte("# syntax=docker/dockerfile:1.2\nFROM scratch")), feature: FeatureCopyLink, want: false},
+		{name: "pinned patch unsupported", directives: ParseDirectives([]byte("# syntax=docker/dockerfile:1.1.7\nFROM scratch")), feature: FeatureRunMount, want: false},
+		{name: "labs", directives: ParseDirectives([]byte("# syntax=docker/dockerfile:1.2-labs\nFROM scratch")), feature: FeatureCopyLink, want: true},
+		{name: "custom frontend", directives: ParseDirectives([]byte("# syntax=example.com/frontend:1.0\nFROM scratch")), feature: FeatureCopyLink, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.directives.Supports(tt.feature); got != tt.want {
+				t.Errorf("Supports() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		wantSyntax    string
+		wantEscape    rune
+		wantCheck     string
+		wantMisplaced []Directive
+	}{
+		{
+			name:       "none",
+			input:      "FROM alpine:3.19\n",
+			wantEscape: '\\',
+		},
+		{
+			name:       "all directives",
+			input:      "# syntax=docker/dockerfile:1\n# escape=`\n# check=error=true\nFROM alpine:3.19\n",
+			wantSyntax: "docker/dockerfile:1",
+			wantEscape: '`',
+			wantCheck:  "error=true",
+		},
+		{
+			name:       "after shebang",
+			input:      "#!/usr/bin/env dockerfile-shebang\n# escape=`\nFROM alpine:3.19\n",
+			wantEscape: '`',
+		},
+		{
+			name:       "after instruction",
+			input:      "FROM alpine:3.19\n# escape=`\nRUN echo hello\n",
+			wantEscape: '\\',
+			wantMisplaced: []Directive{
+				{Name: "escape", Value: "`", Original: "# escape=`", Locations: []Location{{Start: Position{Line: 2}, End: Position{Line: 2}}}},
+			},
+		},
+		{
+			name:       "after comment",
+			input:      "# syntax=docker/dockerfile:1\n# a comment\n#  CHECK = skip=all\nFROM alpine:3.19\n",
+			wantSyntax: "docker/dockerfile:1",
+			wantEscape: '\\',
+			wantMisplaced: []Directive{
+				{Name: "check", Value: "skip=all", Original: "#  CHECK = skip=all", Locations: []Location{{Start: Position{Line: 3}, End: Position{Line: 3}}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDirectives([]byte(tt.input))
+			gotSyntax := ""
+			if got.Syntax != nil {
+				gotSyntax = got.Syntax.Image
+			}
+			if gotSyntax != tt.wantSyntax {
+				t.Errorf("Syntax = %q, want %q", gotSyntax, tt.wantSyntax)
+			}
+			if got.EscapeToken() != tt.wantEscape {
+				t.Errorf("EscapeToken() = %q, want %q", got.EscapeToken(), tt.wantEscape)
+			}
+			gotCheck := ""
+			if got.Check != nil {
+				gotCheck = got.Check.Value
+			}
+			if gotCheck != tt.wantCheck {
+				t.Errorf("Check = %q, want %q", gotCheck, tt.wantCheck)
+			}
+			if !reflect.DeepEqual(got.Misplaced, tt.wantMisplaced) {
+				t.Errorf("Misplaced = %#v, want %#v", got.Misplaced, tt.wantMisplaced)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// DockerIgnore holds the patterns of a .dockerignore file, which exclude files from the build context
+type DockerIgnore struct {
+	// Path of the ignore file
+	Path string
+	// Patterns read from the ignore file, in order
+	Patterns []string
+	matcher  *patternmatcher.PatternMatcher
+}
+
+// NewDockerIgnore creates a DockerIgnore from patterns, using buildkit's pattern matcher
+func NewDockerIgnore(path string, patterns []string) (*DockerIgnore, error) {
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerIgnore{Path: path, Patterns: patterns, matcher: matcher}, nil
+}
+
+// ReadDockerIgnore reads the ignore file at path
+func ReadDockerIgnore(path string) (*DockerIgnore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	patterns, err := ignorefile.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewDockerIgnore(path, patterns)
+}
+
+// FindDockerIgnore locates the ignore file used when building dockerfile, following buildkit's precedence: an ignore file
+// named for the Dockerfile (e.g. Dockerfile.dockerignore) alongside it, then .dockerignore at the root of contextDir.
+// When contextDir is empty, the directory of dockerfile is assumed to be the build context.
+// Returns nil without error if no ignore file exists.
+func FindDockerIgnore(dockerfile string, contextDir string) (*DockerIgnore, error) {
+	if contextDir == "" {
+		contextDir = filepath.Dir(dockerfile)
+	}
+	for _, candidate := range []string{dockerfile + ".dockerignore", filepath.Join(contextDir, ".dockerignore")} {
+		if _, err := os.Stat(candidate); err == nil {
+			return ReadDockerIgnore(candidate)
+		}
+	}
+	return nil, nil
+}
+
+// Ignores determines whether the slash-separated path, relative to the build context, is excluded by the ignore file
+func (i *DockerIgnore) Ignores(p string) bool {
+	if i == nil || i.matcher == nil {
+		return false
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return false
+	}
+	ignored, err := i.matcher.MatchesOrParentMatches(p)
+	return err == nil && ignored
+}
@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDockerIgnore(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		contextDir string
+		want       string
+	}{
+		{name: "next to Dockerfile", dockerfile: "../../testdata/dockerignore/strict/app.dockerfile", want: ".dockerignore"},
+		{name: "Dockerfile variant takes precedence", dockerfile: "../../testdata/dockerignore/variant/app.dockerfile", want: "app.dockerfile.dockerignore"},
+		{name: "from context", dockerfile: "../../testdata/dockerignore/context.dockerfile", contextDir: "../../testdata/dockerignore/context", want: ".dockerignore"},
+		{name: "missing", dockerfile: "../../testdata/dockerignore/missing/app.dockerfile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindDockerIgnore(tt.dockerfile, tt.contextDir)
+			if err != nil {
+				t.Fatalf("FindDockerIgnore error = %v", err)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("FindDockerIgnore() = %s, want nil", got.Path)
+				}
+				return
+			}
+			if got == nil || filepath.Base(got.Path) != tt.want {
+				t.Errorf("FindDockerIgnore() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockerIgnore_Ignores(t *testing.T) {
+	ignore, err := NewDockerIgnore(".dockerignore", []string{".git", ".env*", "!.env.example", "**/*.log", "docs/"})
+	if err != nil {
+		t.Fatalf("NewDockerIgnore error = %v", err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: ".git", want: true},
+		{path: ".git/HEAD", want: true},
+		{path: "./.env", want: true},
+		{path: "/.env.production", want: true},
+		{path: ".env.example", want: false},
+		{path: "logs/app.log", want: true},
+		{path: "docs/index.md", want: true},
+		{path: "src/main.go", want: false},
+		{path: ".", want: false},
+	}
+	for _, tt := range tests {
+		if got := ignore.Ignores(tt.path); got != tt.want {
+			t.Errorf("Ignores(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	var missing *DockerIgnore
+	if missing.Ignores(".git") {
+		t.Errorf("a nil DockerIgnore should not ignore any path")
+	}
+}
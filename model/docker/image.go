@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultRegistry is the registry of images referenced without one, such as python:3
+const DefaultRegistry = "docker.io"
+
+// ImageReference is a parsed image reference of a FROM instruction, e.g. gcr.io/distroless/static:nonroot@sha256:...
+type ImageReference struct {
+	// Registry hosting the image, DefaultRegistry if not specified
+	Registry string
+	// Repository within the registry, including the library/ namespace of official images on DefaultRegistry
+	Repository string
+	// Tag of the image, or empty if not specified (implying latest)
+	Tag string
+	// Digest of the image, e.g. sha256:..., or empty if not pinned
+	Digest string
+}
+
+// ParseImageReference parses image, normalizing the registry and repository of official images
+// (python is docker.io/library/python). Variables are not expanded.
+func ParseImageReference(image string) (ImageReference, error) {
+	ref := ImageReference{}
+	name, digest, hasDigest := strings.Cut(image, "@")
+	if hasDigest {
+		if digest == "" {
+			return ref, fmt.Errorf("invalid image reference %q: empty digest", image)
+		}
+		ref.Digest = digest
+	}
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:idx], name[idx+1:]
+		if ref.Tag == "" {
+			return ref, fmt.Errorf("invalid image reference %q: empty tag", image)
+		}
+	}
+	if name == "" {
+		return ref, fmt.Errorf("invalid image reference %q: empty name", image)
+	}
+
+	ref.Registry = DefaultRegistry
+	if first, rest, found := strings.Cut(name, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, name = first, rest
+	}
+	if ref.Registry == "index.docker.io" {
+		ref.Registry = DefaultRegistry
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name != strings.ToLower(name) {
+		return ref, fmt.Errorf("invalid image reference %q: repository must be lowercase", image)
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+// Name is the familiar name of the image, without tag or digest, omitting the default registry and library/ namespace (e.g. python)
+func (r ImageReference) Name() string {
+	if r.Registry == DefaultRegistry {
+		return strings.TrimPrefix(r.Repository, "library/")
+	}
+	return r.FullName()
+}
+
+// FullName is the fully-qualified name of the image, without tag or digest (e.g. docker.io/library/python)
+func (r ImageReference) FullName() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String formats the familiar form of the reference
+func (r ImageReference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
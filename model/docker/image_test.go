@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		want     ImageReference
+		wantName string
+		wantErr  bool
+	}{
+		{image: "python", want: ImageReference{Registry: "docker.io", Repository: "library/python"}, wantName: "python"},
+		{image: "python:3.12-slim", want: ImageReference{Registry: "docker.io", Repository: "library/python", Tag: "3.12-slim"}, wantName: "python"},
+		{image: "docker.io/library/node:20", want: ImageReference{Registry: "docker.io", Repository: "library/node", Tag: "20"}, wantName: "node"},
+		{image: "bitnami/nginx:1.25", want: ImageReference{Registry: "docker.io", Repository: "bitnami/nginx", Tag: "1.25"}, wantName: "bitnami/nginx"},
+		{
+			image:    "gcr.io/distroless/static:nonroot@sha256:abc123",
+			want:     ImageReference{Registry: "gcr.io", Repository: "distroless/static", Tag: "nonroot", Digest: "sha256:abc123"},
+			wantName: "gcr.io/distroless/static",
+		},
+		{image: "localhost:5000/app", want: ImageReference{Registry: "localhost:5000", Repository: "app"}, wantName: "localhost:5000/app"},
+		{image: "alpine@sha256:abc123", want: ImageReference{Registry: "docker.io", Repository: "library/alpine", Digest: "sha256:abc123"}, wantName: "alpine"},
+		{image: "", wantErr: true},
+		{image: "python:", wantErr: true},
+		{image: "Python:3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := ParseImageReference(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImageReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseImageReference() = %#v, want %#v", got, tt.want)
+			}
+			if got.Name() != tt.wantName {
+				t.Errorf("Name() = %s, want %s", got.Name(), tt.wantName)
+			}
+		})
+	}
+}
@@ -12,3 +12,20 @@ func Instruction(node *parser.Node) (commands.DockerCommand, string) {
 
 	return commands.Of(node.Value), commandText
 }
+
+// OnbuildTrigger extracts the instruction wrapped by an ONBUILD instruction (e.g. RUN in ONBUILD RUN make),
+// positioned at the lines of the ONBUILD instruction. Returns nil when node is not an ONBUILD instruction.
+func OnbuildTrigger(node *parser.Node) *parser.Node {
+	if commands.Of(node.Value) != commands.Onbuild || node.Next == nil || len(node.Next.Children) == 0 {
+		return nil
+	}
+	trigger := *node.Next.Children[0]
+	trigger.StartLine = node.StartLine
+	trigger.EndLine = node.EndLine
+	return &trigger
+}
+
+// IsForbiddenOnbuildTrigger determines whether command is disallowed as an ONBUILD trigger
+func IsForbiddenOnbuildTrigger(command commands.DockerCommand) bool {
+	return command == commands.Onbuild || command == commands.From || command == commands.Maintainer
+}
@@ -44,3 +44,38 @@ func TestInstruction(t *testing.T) {
 		})
 	}
 }
+
+func TestOnbuildTrigger(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantCmd      commands.DockerCommand
+		wantOriginal string
+	}{
+		{name: "not ONBUILD", input: "RUN make"},
+		{name: "RUN", input: "\nONBUILD RUN make \\\n  install", wantCmd: commands.Run, wantOriginal: "RUN make   install"},
+		{name: "nested ONBUILD", input: "ONBUILD ONBUILD COPY . /src", wantCmd: commands.Onbuild, wantOriginal: "ONBUILD COPY . /src"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unable to parse test input: %v", err)
+			}
+			node := result.AST.Children[0]
+			got := OnbuildTrigger(node)
+			if tt.wantOriginal == "" {
+				if got != nil {
+					t.Errorf("OnbuildTrigger() = %#v, want nil", got)
+				}
+				return
+			}
+			if commands.Of(got.Value) != tt.wantCmd || got.Original != tt.wantOriginal {
+				t.Errorf("OnbuildTrigger() = %s (%q), want %s (%q)", commands.Of(got.Value), got.Original, tt.wantCmd, tt.wantOriginal)
+			}
+			if got.StartLine != node.StartLine || got.EndLine != node.EndLine {
+				t.Errorf("OnbuildTrigger() lines = %d-%d, want %d-%d", got.StartLine, got.EndLine, node.StartLine, node.EndLine)
+			}
+		})
+	}
+}
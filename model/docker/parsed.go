@@ -0,0 +1,269 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Flags are the BuildKit flags applied to an instruction, such as COPY --chown or RUN --mount.
+// Only flags supported by the instruction are populated.
+type Flags struct {
+	// From is the stage or image of COPY --from
+	From string `json:"from,omitempty"`
+	// Chown is the owner of ADD/COPY --chown
+	Chown string `json:"chown,omitempty"`
+	// Chmod is the mode of ADD/COPY --chmod
+	Chmod string `json:"chmod,omitempty"`
+	// Link is whether ADD/COPY --link was used
+	Link bool `json:"link,omitempty"`
+	// Parents is whether COPY --parents was used
+	Parents bool `json:"parents,omitempty"`
+	// Exclude holds patterns of ADD/COPY --exclude
+	Exclude []string `json:"exclude,omitempty"`
+	// Checksum is the checksum of ADD --checksum
+	Checksum string `json:"checksum,omitempty"`
+	// Platform is the platform of FROM --platform
+	Platform string `json:"platform,omitempty"`
+	// Mounts are the mounts of RUN --mount
+	Mounts []*instructions.Mount `json:"mounts,omitempty"`
+	// Network is the network mode of RUN --network
+	Network string `json:"network,omitempty"`
+	// Security is the security mode of RUN --security
+	Security string `json:"security,omitempty"`
+	// Used lists the names of all flags present on the instruction
+	Used []string `json:"used,omitempty"`
+}
+
+// ParsedInstruction is a typed representation of a Docker instruction, parsed once via buildkit's instructions package.
+type ParsedInstruction struct {
+	// Command is the instruction's commands.DockerCommand
+	Command commands.DockerCommand
+	// Value is the typed buildkit instruction, for example *instructions.RunCommand (or *instructions.Stage for FROM)
+	Value any
+	// Flags are the BuildKit flags applied to the instruction
+	Flags Flags
+	// Err holds the parse error when buildkit is unable to parse the instruction, in which case Value is nil
+	Err error
+}
+
+// ParseInstruction converts the node into a ParsedInstruction. Parse failures are recorded on ParsedInstruction.Err.
+func ParseInstruction(node *parser.Node) *ParsedInstruction {
+	parsed := &ParsedInstruction{Command: commands.Of(node.Value)}
+	for _, flag := range node.Flags {
+		parsed.Flags.Used = append(parsed.Flags.Used, flagName(flag))
+	}
+
+	value, err := instructions.ParseInstruction(node)
+	if err != nil {
+		parsed.Err = err
+		return parsed
+	}
+	parsed.Value = value
+
+	switch v := value.(type) {
+	case *instructions.AddCommand:
+		parsed.Flags.Chown = v.Chown
+		parsed.Flags.Chmod = v.Chmod
+		parsed.Flags.Link = v.Link
+		parsed.Flags.Exclude = v.ExcludePatterns
+		parsed.Flags.Checksum = v.Checksum
+	case *instructions.CopyCommand:
+		parsed.Flags.From = v.From
+		parsed.Flags.Chown = v.Chown
+		parsed.Flags.Chmod = v.Chmod
+		parsed.Flags.Link = v.Link
+		parsed.Flags.Parents = v.Parents
+		parsed.Flags.Exclude = v.ExcludePatterns
+	case *instructions.Stage:
+		parsed.Flags.Platform = v.Platform
+	case *instructions.RunCommand:
+		// buildkit defers parsing mount options until variables are expanded; variables are kept as-is here
+		if err := v.Expand(func(word string) (string, error) { return word, nil }); err != nil {
+			parsed.Err = err
+		}
+		parsed.Flags.Mounts = instructions.GetMounts(v)
+		parsed.Flags.Network = instructions.GetNetwork(v)
+		parsed.Flags.Security = instructions.GetSecurity(v)
+	}
+
+	return parsed
+}
+
+// Features lists the Dockerfile syntax features used by the instruction which require a minimum frontend version
+func (p *ParsedInstruction) Features() []Feature {
+	features := make([]Feature, 0)
+	if p == nil {
+		return features
+	}
+	for _, flag := range p.Flags.Used {
+		switch {
+		case flag == "mount" && p.Command == commands.Run:
+			features = append(features, FeatureRunMount)
+		case flag == "network" && p.Command == commands.Run:
+			features = append(features, FeatureRunNetwork)
+		case flag == "security" && p.Command == commands.Run:
+			features = append(features, FeatureRunSecurity)
+		case flag == "chmod":
+			features = append(features, FeatureChmod)
+		case flag == "link":
+			features = append(features, FeatureCopyLink)
+		case flag == "parents":
+			features = append(features, FeatureCopyParents)
+		case flag == "exclude":
+			features = append(features, FeatureCopyExclude)
+		case flag == "checksum":
+			features = append(features, FeatureAddChecksum)
+		}
+	}
+
+	heredoc := false
+	switch v := p.Value.(type) {
+	case *instructions.RunCommand:
+		heredoc = len(v.Files) > 0
+	case *instructions.CopyCommand:
+		heredoc = len(v.SourceContents) > 0
+	case *instructions.AddCommand:
+		heredoc = len(v.SourceContents) > 0
+	}
+	if heredoc {
+		features = append(features, FeatureHeredoc)
+	}
+	return features
+}
+
+// flagName extracts the name of a flag such as --mount=type=cache
+func flagName(flag string) string {
+	name, _, _ := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+	return name
+}
+
+// HasFlag determines whether the instruction was defined with the named flag (without leading dashes)
+func (p *ParsedInstruction) HasFlag(name string) bool {
+	if p == nil {
+		return false
+	}
+	for _, used := range p.Flags.Used {
+		if used == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Add returns the typed ADD instruction, or nil
+func (p *ParsedInstruction) Add() *instructions.AddCommand {
+	v, _ := p.value().(*instructions.AddCommand)
+	return v
+}
+
+// Arg returns the typed ARG instruction, or nil
+func (p *ParsedInstruction) Arg() *instructions.ArgCommand {
+	v, _ := p.value().(*instructions.ArgCommand)
+	return v
+}
+
+// Cmd returns the typed CMD instruction, or nil
+func (p *ParsedInstruction) Cmd() *instructions.CmdCommand {
+	v, _ := p.value().(*instructions.CmdCommand)
+	return v
+}
+
+// Copy returns the typed COPY instruction, or nil
+func (p *ParsedInstruction) Copy() *instructions.CopyCommand {
+	v, _ := p.value().(*instructions.CopyCommand)
+	return v
+}
+
+// Entrypoint returns the typed ENTRYPOINT instruction, or nil
+func (p *ParsedInstruction) Entrypoint() *instructions.EntrypointCommand {
+	v, _ := p.value().(*instructions.EntrypointCommand)
+	return v
+}
+
+// Env returns the typed ENV instruction, or nil
+func (p *ParsedInstruction) Env() *instructions.EnvCommand {
+	v, _ := p.value().(*instructions.EnvCommand)
+	return v
+}
+
+// Expose returns the typed EXPOSE instruction, or nil
+func (p *ParsedInstruction) Expose() *instructions.ExposeCommand {
+	v, _ := p.value().(*instructions.ExposeCommand)
+	return v
+}
+
+// From returns the typed FROM instruction (a build stage), or nil
+func (p *ParsedInstruction) From() *instructions.Stage {
+	v, _ := p.value().(*instructions.Stage)
+	return v
+}
+
+// Healthcheck returns the typed HEALTHCHECK instruction, or nil
+func (p *ParsedInstruction) Healthcheck() *instructions.HealthCheckCommand {
+	v, _ := p.value().(*instructions.HealthCheckCommand)
+	return v
+}
+
+// Label returns the typed LABEL instruction, or nil
+func (p *ParsedInstruction) Label() *instructions.LabelCommand {
+	v, _ := p.value().(*instructions.LabelCommand)
+	return v
+}
+
+// Maintainer returns the typed MAINTAINER instruction, or nil
+func (p *ParsedInstruction) Maintainer() *instructions.MaintainerCommand {
+	v, _ := p.value().(*instructions.MaintainerCommand)
+	return v
+}
+
+// Onbuild returns the typed ONBUILD instruction, or nil
+func (p *ParsedInstruction) Onbuild() *instructions.OnbuildCommand {
+	v, _ := p.value().(*instructions.OnbuildCommand)
+	return v
+}
+
+// Run returns the typed RUN instruction, or nil
+func (p *ParsedInstruction) Run() *instructions.RunCommand {
+	v, _ := p.value().(*instructions.RunCommand)
+	return v
+}
+
+// Shell returns the typed SHELL instruction, or nil
+func (p *ParsedInstruction) Shell() *instructions.ShellCommand {
+	v, _ := p.value().(*instructions.ShellCommand)
+	return v
+}
+
+// StopSignal returns the typed STOPSIGNAL instruction, or nil
+func (p *ParsedInstruction) StopSignal() *instructions.StopSignalCommand {
+	v, _ := p.value().(*instructions.StopSignalCommand)
+	return v
+}
+
+// User returns the typed USER instruction, or nil
+func (p *ParsedInstruction) User() *instructions.UserCommand {
+	v, _ := p.value().(*instructions.UserCommand)
+	return v
+}
+
+// Volume returns the typed VOLUME instruction, or nil
+func (p *ParsedInstruction) Volume() *instructions.VolumeCommand {
+	v, _ := p.value().(*instructions.VolumeCommand)
+	return v
+}
+
+// Workdir returns the typed WORKDIR instruction, or nil
+func (p *ParsedInstruction) Workdir() *instructions.WorkdirCommand {
+	v, _ := p.value().(*instructions.WorkdirCommand)
+	return v
+}
+
+func (p *ParsedInstruction) value() any {
+	if p == nil {
+		return nil
+	}
+	return p.Value
+}
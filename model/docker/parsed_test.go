@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func TestParseInstruction(t *testing.T) {
+	parse := func(input string) *parser.Node {
+		result, err := parser.Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("unable to parse test input: %v", err)
+		}
+		return result.AST.Children[0]
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		wantCmd   commands.DockerCommand
+		wantFlags Flags
+		wantErr   bool
+		typed     func(p *ParsedInstruction) bool
+	}{
+		{
+			name:    "FROM --platform",
+			input:   "FROM --platform=linux/amd64 alpine:3.19 AS builder",
+			wantCmd: commands.From,
+			wantFlags: Flags{
+				Platform: "linux/amd64",
+				Used:     []string{"platform"},
+			},
+			typed: func(p *ParsedInstruction) bool {
+				return p.From().BaseName == "alpine:3.19" && p.From().Name == "builder"
+			},
+		},
+		{
+			name:    "COPY flags",
+			input:   "COPY --from=builder --chown=app:app --chmod=0755 --link /go/bin/app /app",
+			wantCmd: commands.Copy,
+			wantFlags: Flags{
+				From:  "builder",
+				Chown: "app:app",
+				Chmod: "0755",
+				Link:  true,
+				Used:  []string{"from", "chown", "chmod", "link"},
+			},
+			typed: func(p *ParsedInstruction) bool {
+				return p.Copy().DestPath == "/app" && p.Add() == nil
+			},
+		},
+		{
+			name:    "ADD flags",
+			input:   "ADD --checksum=sha256:abc --exclude=*.md https://example.com/a.tgz /a",
+			wantCmd: commands.Add,
+			wantFlags: Flags{
+				Exclude:  []string{"*.md"},
+				Checksum: "sha256:abc",
+				Used:     []string{"checksum", "exclude"},
+			},
+			typed: func(p *ParsedInstruction) bool {
+				return p.Add().SourcePaths[0] == "https://example.com/a.tgz"
+			},
+		},
+		{
+			name:    "RUN flags",
+			input:   "RUN --mount=type=cache,target=/root/.cache --network=host --security=insecure pip install x",
+			wantCmd: commands.Run,
+			wantFlags: Flags{
+				Mounts:   []*instructions.Mount{{Type: instructions.MountTypeCache, Target: "/root/.cache"}},
+				Network:  "host",
+				Security: "insecure",
+				Used:     []string{"mount", "network", "security"},
+			},
+			typed: func(p *ParsedInstruction) bool {
+				return p.Run().CmdLine[0] == "pip install x"
+			},
+		},
+		{
+			name:    "USER",
+			input:   "USER app",
+			wantCmd: commands.User,
+			typed: func(p *ParsedInstruction) bool {
+				return p.User().User == "app"
+			},
+		},
+		{
+			name:    "invalid instruction",
+			input:   "USER",
+			wantCmd: commands.User,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseInstruction(parse(tt.input))
+			if (got.Err != nil) != tt.wantErr {
+				t.Errorf("ParseInstruction() error = %v, wantErr %v", got.Err, tt.wantErr)
+				return
+			}
+			if got.Command != tt.wantCmd {
+				t.Errorf("ParseInstruction() command = %v, want %v", got.Command, tt.wantCmd)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.Flags, tt.wantFlags) {
+				t.Errorf("ParseInstruction() flags = %#v, want %#v", got.Flags, tt.wantFlags)
+			}
+			if tt.typed != nil && !tt.typed(got) {
+				t.Errorf("ParseInstruction() typed value = %#v", got.Value)
+			}
+		})
+	}
+}
+
+func TestParsedInstruction_Features(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Feature
+	}{
+		{name: "plain RUN", input: "RUN echo hello", want: []Feature{}},
+		{name: "RUN flags", input: "RUN --mount=type=secret,id=token --network=none echo hello", want: []Feature{FeatureRunMount, FeatureRunNetwork}},
+		{name: "RUN heredoc", input: "RUN <<EOF\necho hello\nEOF", want: []Feature{FeatureHeredoc}},
+		{name: "COPY flags", input: "COPY --link --chmod=755 --exclude=*.md . /app", want: []Feature{FeatureCopyLink, FeatureChmod, FeatureCopyExclude}},
+		{name: "COPY heredoc", input: "COPY <<EOF /etc/motd\nhello\nEOF", want: []Feature{FeatureHeredoc}},
+		{name: "ADD checksum", input: "ADD --checksum=sha256:abc https://example.com/a.tgz /", want: []Feature{FeatureAddChecksum}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unable to parse test input: %v", err)
+			}
+			if got := ParseInstruction(result.AST.Children[0]).Features(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Features() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
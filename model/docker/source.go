@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Source is the raw text of an instruction as written in the Dockerfile, including continuation lines and heredocs.
+// Parsed instructions lose the original columns of their content, so Source allows locating text within the instruction.
+type Source struct {
+	// StartLine is the 1-based line number of the first line in Lines
+	StartLine int
+	// Lines of the instruction
+	Lines []string
+}
+
+// NewSource extracts the raw text of node from the lines of its Dockerfile
+func NewSource(lines []string, node *parser.Node) *Source {
+	if node.StartLine < 1 || node.EndLine > len(lines) || node.StartLine > node.EndLine {
+		return nil
+	}
+	return &Source{StartLine: node.StartLine, Lines: lines[node.StartLine-1 : node.EndLine]}
+}
+
+// Find locates the first occurrence of text as a whole word (delimited by whitespace, quotes, or shell operators)
+// at or after from. A zero Position for from searches the entire instruction.
+// Returns the Location of text, with 0-based start and end characters, or false if text isn't found.
+func (s *Source) Find(text string, from Position) (Location, bool) {
+	return s.find(text, from, true)
+}
+
+// FindText locates the first occurrence of text at or after from, which may be part of a larger word (e.g. the key
+// of key=value). A zero Position for from searches the entire instruction.
+func (s *Source) FindText(text string, from Position) (Location, bool) {
+	return s.find(text, from, false)
+}
+
+func (s *Source) find(text string, from Position, wholeWord bool) (Location, bool) {
+	if s == nil || text == "" {
+		return Location{}, false
+	}
+	for i, line := range s.Lines {
+		lineNumber := s.StartLine + i
+		if lineNumber < from.Line {
+			continue
+		}
+		offset := 0
+		if lineNumber == from.Line {
+			offset = from.Character
+		}
+		for offset <= len(line)-len(text) {
+			idx := strings.Index(line[offset:], text)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			end := start + len(text)
+			if !wholeWord || (isWordBoundary(line, start-1) && isWordBoundary(line, end)) {
+				return Location{
+					Start: Position{Line: lineNumber, Character: start},
+					End:   Position{Line: lineNumber, Character: end},
+				}, true
+			}
+			offset = start + 1
+		}
+	}
+	return Location{}, false
+}
+
+// isWordBoundary determines whether the character at idx of line delimits a word; the start and end of a line are boundaries
+func isWordBoundary(line string, idx int) bool {
+	if idx < 0 || idx >= len(line) {
+		return true
+	}
+	return strings.ContainsRune(" \t\r'\"`;&|()<>\\", rune(line[idx]))
+}
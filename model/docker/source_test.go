@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func TestSource_Find(t *testing.T) {
+	dockerfile := "FROM debian\nRUN apt-get install -y \\\n    libcurl4 curl \\\n    curl-dev curl\n"
+	result, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("unable to parse test input: %v", err)
+	}
+	source := NewSource(strings.Split(dockerfile, "\n"), result.AST.Children[1])
+
+	tests := []struct {
+		name   string
+		text   string
+		from   Position
+		want   Location
+		wantOk bool
+	}{
+		{name: "whole word", text: "curl", want: Location{Start: pos(3, 13), End: pos(3, 17)}, wantOk: true},
+		{name: "after position", text: "curl", from: pos(3, 17), want: Location{Start: pos(4, 13), End: pos(4, 17)}, wantOk: true},
+		{name: "hyphenated word", text: "curl-dev", want: Location{Start: pos(4, 4), End: pos(4, 12)}, wantOk: true},
+		{name: "command", text: "apt-get", want: Location{Start: pos(2, 4), End: pos(2, 11)}, wantOk: true},
+		{name: "outside instruction", text: "debian", wantOk: false},
+		{name: "missing", text: "wget", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := source.Find(tt.text, tt.from)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("Find() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSource_FindText(t *testing.T) {
+	dockerfile := "FROM debian\nLABEL version=1.0 \\\n    org.example.version=\"1.0\"\n"
+	result, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("unable to parse test input: %v", err)
+	}
+	source := NewSource(strings.Split(dockerfile, "\n"), result.AST.Children[1])
+
+	tests := []struct {
+		name   string
+		text   string
+		from   Position
+		want   Location
+		wantOk bool
+	}{
+		{name: "key", text: "version", want: Location{Start: pos(2, 6), End: pos(2, 13)}, wantOk: true},
+		{name: "value", text: "1.0", from: pos(2, 13), want: Location{Start: pos(2, 14), End: pos(2, 17)}, wantOk: true},
+		{name: "within word", text: "version", from: pos(2, 17), want: Location{Start: pos(3, 16), End: pos(3, 23)}, wantOk: true},
+		{name: "quoted value", text: "1.0", from: pos(3, 23), want: Location{Start: pos(3, 25), End: pos(3, 28)}, wantOk: true},
+		{name: "missing", text: "2.0", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := source.FindText(tt.text, tt.from)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("FindText() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Stage identifies the build stage holding an instruction
+type Stage struct {
+	// Index of the stage, counting each FROM from 0
+	Index int
+	// Name is the lower-case name of the stage (FROM image AS name), or empty when unnamed
+	Name string
+	// BaseName is the lower-case image or stage the stage is built from
+	BaseName string
+	// Base is the earlier stage the stage is built from (FROM build), or nil when built from an image
+	Base *Stage
+	// InFinalImage is whether the stage is the final stage, or a stage the final stage is built from
+	InFinalImage bool
+}
+
+// BuildStages finds the build stages of a Dockerfile from its FROM instructions, in order
+func BuildStages(nodes []*parser.Node) []*Stage {
+	stages := make([]*Stage, 0)
+	named := make(map[string]*Stage)
+	for _, node := range nodes {
+		from := ParseInstruction(node).From()
+		if from == nil {
+			continue
+		}
+		stage := &Stage{Index: len(stages), Name: strings.ToLower(from.Name), BaseName: strings.ToLower(from.BaseName)}
+		stage.Base = named[stage.BaseName]
+		if stage.Name != "" {
+			named[stage.Name] = stage
+		}
+		stages = append(stages, stage)
+	}
+
+	// the final image holds the layers of the final stage and each stage it's built from
+	if len(stages) > 0 {
+		for stage := stages[len(stages)-1]; stage != nil; stage = stage.Base {
+			stage.InFinalImage = true
+		}
+	}
+	return stages
+}
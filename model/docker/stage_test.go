@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildStages(t *testing.T) {
+	dockerfile := `ARG VERSION=1.25
+FROM golang:${VERSION} AS Build
+RUN go build -o /app .
+
+FROM build AS test
+RUN go test ./...
+
+FROM alpine:3.20 AS tools
+
+FROM build
+COPY --from=tools /bin/busybox /bin/busybox
+`
+	result, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatalf("parser.Parse() error = %v", err)
+	}
+
+	stages := BuildStages(result.AST.Children)
+	if !assert.Len(t, stages, 4) {
+		return
+	}
+	build, test, tools, final := stages[0], stages[1], stages[2], stages[3]
+	assert.Equal(t, Stage{Index: 0, Name: "build", BaseName: "golang:${version}", InFinalImage: true}, *build)
+	assert.Equal(t, 1, test.Index)
+	assert.Same(t, build, test.Base)
+	assert.False(t, test.InFinalImage)
+	assert.Nil(t, tools.Base)
+	assert.False(t, tools.InFinalImage)
+	assert.Equal(t, "", final.Name)
+	assert.Same(t, build, final.Base)
+	assert.True(t, final.InFinalImage)
+}
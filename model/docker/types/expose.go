@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
@@ -46,19 +47,22 @@ type PortRange struct {
 // Parse an expose definition string into a typed Expose value, error if port definition is not valid
 //goland:noinspection GoBoolExpressions
 func (e *Expose) Parse(def string) error {
-	parts := strings.FieldsFunc(def, func(r rune) bool {
-		return r == '/'
-	})
+	parts := strings.Split(def, "/")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid port definition: %s", def)
+	}
 	portRange := PortRange{}
-	portRange.Of(parts[0])
+	if _, err := portRange.Parse(parts[0]); err != nil {
+		return err
+	}
 	if !portRange.IsValid() {
-		return fmt.Errorf("invalid port range: start=(%d) end=(%d)", portRange.Start, portRange.End)
+		return fmt.Errorf("invalid port range: %s is not within 1-65535", parts[0])
 	}
 
 	if len(parts) > 1 {
 		userProtocol := parts[1]
 		protocol := strings.ToLower(userProtocol)
-		if protocol != "tcp" && protocol != "udp" && protocol != "" {
+		if protocol != "tcp" && protocol != "udp" && protocol != "sctp" && protocol != "" {
 			return fmt.Errorf("invalid protocol used in EXPOSE command: %s", parts[1])
 		}
 		e.Protocol = Protocol(protocol)
@@ -70,6 +74,35 @@ func (e *Expose) Parse(def string) error {
 	return nil
 }
 
+// String formats the exposed port as in EXPOSE, followed by any description, e.g. 53/udp (DNS)
+func (e Expose) String() string {
+	buf := bytes.Buffer{}
+	buf.WriteString(fmt.Sprintf("%d", e.PortRange.Start))
+	if e.PortRange.End > e.PortRange.Start {
+		buf.WriteString(fmt.Sprintf("-%d", e.PortRange.End))
+	}
+	if e.Protocol != "" {
+		buf.WriteString(fmt.Sprintf("/%s", e.Protocol))
+	}
+	if e.Description != nil {
+		buf.WriteString(fmt.Sprintf(" (%s)", *e.Description))
+	}
+	return buf.String()
+}
+
+// Overlaps determines whether two exposed ports share a port of the same protocol, where an empty protocol is tcp
+func (e Expose) Overlaps(other Expose) bool {
+	return e.Protocol.orDefault() == other.Protocol.orDefault() && e.PortRange.Intersects(other.PortRange)
+}
+
+// orDefault is the protocol, or tcp when empty
+func (p Protocol) orDefault() Protocol {
+	if p == "" {
+		return "tcp"
+	}
+	return p
+}
+
 // Of returns the valid PortRange representation of the pass string input
 func (p *PortRange) Of(input string) *PortRange {
 	ports := strings.FieldsFunc(input, func(r rune) bool {
@@ -92,6 +125,30 @@ func (p *PortRange) Of(input string) *PortRange {
 	return p
 }
 
+// Parse returns the PortRange of input (8080 or 8000-8099), or an error if input isn't a port or ascending range of ports
+func (p *PortRange) Parse(input string) (*PortRange, error) {
+	start, end, isRange := strings.Cut(input, "-")
+	if !isRange {
+		end = start
+	}
+	var err error
+	if p.Start, err = strconv.Atoi(start); err != nil {
+		return p, fmt.Errorf("invalid port: %s", input)
+	}
+	if p.End, err = strconv.Atoi(end); err != nil {
+		return p, fmt.Errorf("invalid port: %s", input)
+	}
+	if p.End < p.Start {
+		return p, fmt.Errorf("invalid port range: start=(%d) is greater than end=(%d)", p.Start, p.End)
+	}
+	return p, nil
+}
+
+// Contains determines whether every port of other is within the range
+func (p *PortRange) Contains(other PortRange) bool {
+	return p.Start <= other.Start && other.End <= p.End
+}
+
 // IsValid determines if the defined ports are within the valid port range (a short)
 func (p *PortRange) IsValid() bool {
 	return 0 < p.Start && p.Start <= 65535 && 0 < p.End && p.End <= 65535
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"sort"
+
+	lexer "github.com/moby/buildkit/frontend/dockerfile/shell"
+)
+
+// Variables are the values of ARG and ENV in effect at an instruction, by name
+type Variables map[string]string
+
+// Expand replaces the variables of word as the Dockerfile frontend does (e.g. ${PORT:-8080}), returning the sorted names of
+// referenced variables without a value
+func (v Variables) Expand(word string, escapeToken rune) (string, []string, error) {
+	env := make([]string, 0, len(v))
+	for key, value := range v {
+		env = append(env, key+"="+value)
+	}
+	result, err := lexer.NewLex(escapeToken).ProcessWordWithMatches(word, lexer.EnvsFromSlice(env))
+	if err != nil {
+		return word, nil, err
+	}
+	unmatched := make([]string, 0, len(result.Unmatched))
+	for name := range result.Unmatched {
+		unmatched = append(unmatched, name)
+	}
+	sort.Strings(unmatched)
+	return result.Result, unmatched, nil
+}
+
+// With returns a copy of the variables, updated by the ARG and ENV instruction parsed. ARGs without a default value take the value of
+// the same ARG in meta, the ARGs declared before the first FROM. Values are expanded using the variables before the instruction.
+func (v Variables) With(parsed *ParsedInstruction, meta Variables, escapeToken rune) Variables {
+	next := make(Variables, len(v))
+	for key, value := range v {
+		next[key] = value
+	}
+	expand := func(value string) string {
+		if expanded, _, err := v.Expand(value, escapeToken); err == nil {
+			return expanded
+		}
+		return value
+	}
+	if arg := parsed.Arg(); arg != nil {
+		for _, pair := range arg.Args {
+			if pair.Value != nil {
+				next[pair.Key] = expand(*pair.Value)
+			} else if value, ok := meta[pair.Key]; ok {
+				next[pair.Key] = value
+			}
+		}
+	}
+	if env := parsed.Env(); env != nil {
+		for _, pair := range env.Env {
+			next[pair.Key] = expand(pair.Value)
+		}
+	}
+	return next
+}
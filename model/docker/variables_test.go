@@ -0,0 +1,70 @@
+package docker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func TestVariables_Expand(t *testing.T) {
+	variables := Variables{"PORT": "8080", "EMPTY": ""}
+	tests := []struct {
+		name           string
+		word           string
+		want           string
+		wantUnresolved []string
+	}{
+		{name: "variable", word: "$PORT/tcp", want: "8080/tcp", wantUnresolved: []string{}},
+		{name: "braced variable", word: "${PORT}", want: "8080", wantUnresolved: []string{}},
+		{name: "undefined variables", word: "$DEBUG_PORT${GRPC_PORT}", want: "", wantUnresolved: []string{"DEBUG_PORT", "GRPC_PORT"}},
+		{name: "default value", word: "${GRPC_PORT:-9000}", want: "9000", wantUnresolved: []string{"GRPC_PORT"}},
+		{name: "empty value", word: "${EMPTY}", want: "", wantUnresolved: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := variables.Expand(tt.word, '\\')
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got != tt.want || !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("Expand() = %q, %v, want %q, %v", got, unresolved, tt.want, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestVariables_With(t *testing.T) {
+	parse := func(instruction string) *ParsedInstruction {
+		result, err := parser.Parse(strings.NewReader(instruction))
+		if err != nil {
+			t.Fatalf("parser.Parse() error = %v", err)
+		}
+		return ParseInstruction(result.AST.Children[0])
+	}
+	meta := Variables{"VERSION": "1.25"}
+	tests := []struct {
+		name        string
+		variables   Variables
+		instruction string
+		want        Variables
+	}{
+		{name: "arg with default", variables: Variables{}, instruction: "ARG PORT=8080", want: Variables{"PORT": "8080"}},
+		{name: "arg redeclaring meta arg", variables: Variables{}, instruction: "ARG VERSION", want: Variables{"VERSION": "1.25"}},
+		{name: "arg without value", variables: Variables{}, instruction: "ARG DEBUG", want: Variables{}},
+		{name: "env expanded before set", variables: Variables{"PORT": "8080"}, instruction: "ENV PORT=9090 ADMIN=$PORT", want: Variables{"PORT": "9090", "ADMIN": "8080"}},
+		{name: "other instruction", variables: Variables{"PORT": "8080"}, instruction: "EXPOSE $PORT", want: Variables{"PORT": "8080"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(tt.variables)
+			if got := tt.variables.With(parse(tt.instruction), meta, '\\'); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("With() = %v, want %v", got, tt.want)
+			}
+			if len(tt.variables) != before {
+				t.Errorf("With() modified the variables before the instruction")
+			}
+		})
+	}
+}
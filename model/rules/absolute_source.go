@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func absoluteSourcePath() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "absolute-source-path",
+		Summary: "ADD and COPY sources should be relative to the build context",
+		Details: "Sources are always resolved within the build context, so an absolute path such as /etc/app.conf refers to etc/app.conf of the context " +
+			"rather than the host. Use a relative path to make this explicit.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Add, commands.Copy},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#source"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				parsed := instructionOf(node, validationContext)
+				if parsed.Err != nil {
+					return model.Skipped
+				}
+
+				var sources []string
+				if copyCommand := parsed.Copy(); copyCommand != nil && copyCommand.From == "" {
+					sources = copyCommand.SourcePaths
+				} else if addCommand := parsed.Add(); addCommand != nil {
+					sources = addCommand.SourcePaths
+				}
+				for _, source := range sources {
+					if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+						continue
+					}
+					if path.IsAbs(source) {
+						return model.Failure
+					}
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(absoluteSourcePath())
+}
@@ -1,64 +1,65 @@
 package rules
 
 import (
-	"strings"
+	"fmt"
 
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
-	"github.com/jimschubert/docked/model/shell"
 	"github.com/jimschubert/docked/model/validations"
-	log "github.com/sirupsen/logrus"
 )
 
 func aptGetUpdateInstall() validations.Rule {
 	r := validations.MultiContextRule{
-		Name:     "apt-get-update-install",
-		Summary:  "You must perform apt-get update and install in same RUN layer",
-		Details:  "Having apt-get update and install in separate RUN layers will break caching. " +
+		Name:    "apt-get-update-install",
+		Summary: "You must perform apt-get update and install in same RUN layer",
+		Details: "Having apt-get update and install in separate RUN layers will break caching: the cached package lists of the update layer " +
+			"are reused by later builds, installing outdated packages or failing once they are removed from the mirror. " +
 			"Having install without update is not recommended. Include both commands in the same layer.",
-		Priority: model.CriticalPriority,
-		Commands: []commands.DockerCommand{commands.Run},
-		URL:      model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#apt-get"),
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#apt-get"),
 		Evaluator: validations.MultiContextFullEvaluator{
 			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
-				var updateIdx *int
-				var installIdx *int
-				result := model.Success
-				for idx, nodeContext := range *mcr.ContextCache {
-					posixCommands, err := shell.NewPosixCommandFromNode(&nodeContext.Node)
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				usesAptGet := false
+				// findings by the line of their RUN instruction
+				findings := make(map[int][]scriptFinding)
+				unevaluated := unevaluatedRuns{}
+				for _, nodeContext := range *mcr.ContextCache {
+					posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
 					if err != nil {
-						log.Warnf("Unable to parse RUN command, validation not evaluated: %#v", nodeContext.Node.Location())
-						return &validations.ValidationResult{
-							Result:  model.Skipped,
-							Details: mcr.GetSummary(),
-						}
+						unevaluated.add(nodeContext.Context, err)
+						continue
 					}
 
-					for _, command := range posixCommands {
-						commandName := strings.TrimLeft(command.Name, `\`)
-						if commandName == "apt-get" {
-							current := idx
-							switch command.Args[0] {
-							case "install":
-								installIdx = &current
-							case "update":
-								updateIdx = &current
+					updated := false
+					for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+						if _, ok := findInstallCommand(command.PosixCommand, aptUpdates); ok {
+							usesAptGet, updated = true, true
+							continue
+						}
+						if _, ok := findInstallCommand(command.PosixCommand, managerLookup("apt-get")); ok {
+							usesAptGet = true
+							if !updated {
+								findings[nodeContext.Node.StartLine] = append(findings[nodeContext.Node.StartLine], scriptFinding{
+									Location: commandSpan(command),
+									Text:     fmt.Sprintf("apt-get install on line %d", nodeContext.Node.StartLine),
+								})
 							}
 						}
 					}
 				}
-				if installIdx == nil && updateIdx == nil {
-					result = model.Skipped
-				} else if installIdx != nil {
-					if updateIdx == nil || (*installIdx > *updateIdx) {
-						result = model.Failure
-					}
-				}
-				return &validations.ValidationResult{
-					Result:   result,
-					Details:  mcr.GetSummary(),
-					Contexts: *mcr.GetContexts(),
+
+				if !usesAptGet {
+					return unevaluated.result(validations.NewValidationResultSkipped(mcr.GetSummary()))
 				}
+				return unevaluated.result(runFindings(mcr, model.Failure, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+					return findings[nodeContext.Node.StartLine]
+				}))
 			},
 		},
 	}
@@ -6,7 +6,6 @@ import (
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/validations"
-	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
@@ -20,16 +19,17 @@ func avoidAddExternal() validations.Rule {
 		Priority:         model.CriticalPriority,
 		Commands:         []commands.DockerCommand{commands.Add},
 		AppliesToBuilder: false,
+		AppliesToOnbuild: true,
 		URL:              model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#add-or-copy"),
 		Evaluator: validations.MultiContextPerNodeEvaluator{
 			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
-				parsed, err := instructions.ParseInstruction(node)
-				if err != nil {
+				parsed := instructionOf(node, validationContext)
+				if parsed.Err != nil {
 					return model.Skipped
 				}
 
 				result := model.Success
-				if add, ok := parsed.(*instructions.AddCommand); ok && add != nil && len(add.SourcePaths) > 0 {
+				if add := parsed.Add(); add != nil && len(add.SourcePaths) > 0 {
 					input := add.SourcePaths[0]
 					if strings.HasPrefix(input, "http:") || strings.HasPrefix(input, "https:") || strings.HasPrefix(input, "file:") {
 						result = model.Failure
@@ -1,27 +1,38 @@
 package rules
 
 import (
-	"strings"
-
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
 func avoidCopyAll() validations.Rule {
 	r := validations.MultiContextRule{
-		Name:     "avoid-copy-all",
-		Summary:  "Avoid copying entire source directory into image",
-		Details:  "Explicitly copying sources helps avoid accidentally persisting secrets or other files that should not be shared.",
-		Priority: model.HighPriority,
-		Commands: []commands.DockerCommand{commands.Copy},
+		Name:    "avoid-copy-all",
+		Summary: "Avoid copying entire source directory into image",
+		Details: "Explicitly copying sources helps avoid accidentally persisting secrets or other files that should not be shared. " +
+			"Copying the entire directory is fine when a .dockerignore excludes version control and secrets.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Copy, commands.Add},
+		AppliesToOnbuild: true,
 		Evaluator: validations.MultiContextPerNodeEvaluator{
 			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
-				if strings.HasPrefix(node.Original, "COPY . ") {
-					return model.Recommendation
+				parsed := instructionOf(node, validationContext)
+				var sourcesAndDest *instructions.SourcesAndDest
+				if copyCommand := parsed.Copy(); copyCommand != nil && copyCommand.From == "" {
+					sourcesAndDest = &copyCommand.SourcesAndDest
+				} else if addCommand := parsed.Add(); addCommand != nil {
+					sourcesAndDest = &addCommand.SourcesAndDest
+				}
+				if sourcesAndDest == nil || !copiesSourceTree(sourcesAndDest) {
+					return model.Skipped
+				}
+				if validationContext.DockerIgnore != nil && len(unignoredSensitivePaths(validationContext)) == 0 {
+					return model.Success
 				}
-				return model.Skipped
+				return model.Recommendation
 			},
 		},
 	}
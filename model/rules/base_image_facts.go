@@ -0,0 +1,293 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/catalog"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	log "github.com/sirupsen/logrus"
+)
+
+// osPackageManagers are the package managers provided by a distribution, as opposed to those of a language ecosystem,
+// by the distribution family which provides them
+var osPackageManagers = map[string]string{
+	"apt":      "debian",
+	"apt-get":  "debian",
+	"apk":      "alpine",
+	"yum":      "rhel",
+	"dnf":      "rhel",
+	"microdnf": "rhel",
+	"zypper":   "suse",
+	"pacman":   "arch",
+}
+
+// distroHints are words in the names or tags of images missing from the catalog (e.g. myorg/base:1.0-alpine3.21) which
+// identify the distribution, by the catalog image describing that distribution
+var distroHints = map[string]string{
+	"alpine":     "alpine",
+	"debian":     "debian",
+	"bookworm":   "debian",
+	"bullseye":   "debian",
+	"trixie":     "debian",
+	"ubuntu":     "ubuntu",
+	"jammy":      "ubuntu",
+	"noble":      "ubuntu",
+	"fedora":     "fedora",
+	"rockylinux": "rockylinux",
+	"almalinux":  "almalinux",
+	"opensuse":   "opensuse/leap",
+	"archlinux":  "archlinux",
+}
+
+var (
+	catalogs     = make(map[string]*catalog.Catalog)
+	catalogsLock = sync.Mutex{}
+)
+
+// imageCatalog returns the built-in catalog of base images, extended by the file configured via Settings.ImageCatalog
+func imageCatalog(settings Settings) *catalog.Catalog {
+	overrides := settings.ImageCatalog
+	catalogsLock.Lock()
+	defer catalogsLock.Unlock()
+	if c, ok := catalogs[overrides]; ok {
+		return c
+	}
+
+	c := catalog.Default()
+	if overrides != "" {
+		loaded, err := catalog.Load(overrides)
+		if err != nil {
+			log.Warnf("Unable to load image catalog %s, using the built-in catalog: %s", overrides, err)
+		} else {
+			c = c.WithOverrides(loaded)
+		}
+	}
+	catalogs[overrides] = c
+	return c
+}
+
+// inferImageFacts describes an image missing from the catalog by the distribution named in its name or tag
+func inferImageFacts(c *catalog.Catalog, image string) (catalog.ImageFacts, bool) {
+	ref, err := docker.ParseImageReference(image)
+	if err != nil {
+		return catalog.ImageFacts{}, false
+	}
+	words := strings.FieldsFunc(ref.Name()+" "+ref.Tag, func(r rune) bool { return r < 'a' || r > 'z' })
+	for _, word := range words {
+		if hint, ok := distroHints[word]; ok {
+			return c.Lookup(hint)
+		}
+	}
+	return catalog.ImageFacts{}, false
+}
+
+// stageImageFacts describes the image which the stage of validationContext is ultimately built from,
+// as found in the image catalog or inferred from its name
+func stageImageFacts(validationContext validations.ValidationContext) (catalog.ImageFacts, bool) {
+	stage := validationContext.Stage
+	if stage == nil {
+		return catalog.ImageFacts{}, false
+	}
+	for stage.Base != nil {
+		stage = stage.Base
+	}
+	c := imageCatalog(settingsOf(validationContext))
+	if facts, found := c.Lookup(stage.BaseName); found {
+		return facts, true
+	}
+	return inferImageFacts(c, stage.BaseName)
+}
+
+// stageImage is the base image of a build stage
+type stageImage struct {
+	// image is the reference of the base image, as written in FROM
+	image string
+	// facts describes the base image, or is nil if the image isn't in the catalog and its distribution can't be inferred
+	facts *catalog.ImageFacts
+	// manager is the first OS package manager invoked in the stage, which identifies the distribution when facts is nil
+	manager string
+}
+
+// stageImages tracks the base image of the current build stage. Stages built from a named stage inherit its base image.
+type stageImages struct {
+	catalog *catalog.Catalog
+	current *stageImage
+	named   map[string]*stageImage
+}
+
+// newStageImages creates a stageImages using the imageCatalog configured by settings
+func newStageImages(settings Settings) *stageImages {
+	return &stageImages{catalog: imageCatalog(settings), current: &stageImage{}, named: make(map[string]*stageImage)}
+}
+
+// track updates the current stage for a FROM instruction, returning whether parsed starts a new stage
+func (s *stageImages) track(parsed *docker.ParsedInstruction) bool {
+	stage := parsed.From()
+	if stage == nil {
+		return false
+	}
+	if inherited, ok := s.named[strings.ToLower(stage.BaseName)]; ok {
+		image := *inherited
+		s.current = &image
+	} else {
+		s.current = &stageImage{image: stage.BaseName}
+		facts, found := s.catalog.Lookup(stage.BaseName)
+		if !found {
+			facts, found = inferImageFacts(s.catalog, stage.BaseName)
+		}
+		if found {
+			s.current.facts = &facts
+		}
+	}
+	if stage.Name != "" {
+		s.named[strings.ToLower(stage.Name)] = s.current
+	}
+	return true
+}
+
+func runWithoutShell() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "run-without-shell",
+		Summary: "RUN in shell form requires a shell in the base image",
+		Details: "Shell-form instructions run via /bin/sh, which images such as scratch and distroless don't include, so the build fails. " +
+			"Use the exec form, build in a separate stage and copy the results, or select an included shell via SHELL (e.g. /busybox/sh in distroless debug images). " +
+			"Base images are described by a built-in catalog, which may be extended via settings.image_catalog.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#shell-form"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				stages := newStageImages(ruleSettings(mcr))
+				found := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					if stages.track(instructionOf(&nodeContext.Node, nodeContext.Context)) {
+						continue
+					}
+					facts := stages.current.facts
+					if nodeContext.Node.Attributes["json"] || len(nodeContext.Context.Shell) > 0 || facts == nil || facts.Shell == catalog.DefaultShell {
+						continue
+					}
+					if !model.StringSliceContains(&found, stages.current.image) {
+						found = append(found, stages.current.image)
+					}
+					validationContext := nodeContext.Context
+					validationContext.CausedFailure = true
+					validationContexts = append(validationContexts, validationContext)
+				}
+
+				if len(found) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Without %s: %s", mcr.GetSummary(), catalog.DefaultShell, strings.Join(found, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func packageManagerMismatch() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "package-manager-mismatch",
+		Summary: "Use the package manager of the base image's distribution",
+		Details: "Package managers are specific to a distribution, for example apk in alpine and apt-get in debian or ubuntu. " +
+			"Invoking one which the base image doesn't include, or mixing those of different distributions in a stage, fails the build. " +
+			"Base images are described by a built-in catalog, which may be extended via settings.image_catalog. " +
+			"The distribution of other images is inferred from their name or tag (e.g. 1.0-alpine), or from the first package manager invoked in the stage.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/best-practices/#run"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				stages := newStageImages(ruleSettings(mcr))
+				found := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				unevaluated := unevaluatedRuns{}
+				for _, nodeContext := range *mcr.ContextCache {
+					if stages.track(instructionOf(&nodeContext.Node, nodeContext.Context)) {
+						continue
+					}
+					posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+					if err != nil {
+						unevaluated.add(nodeContext.Context, err)
+						continue
+					}
+					for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+						current := stages.current
+						name := strings.TrimLeft(command.Name, `\`)
+						distro, ok := osPackageManagers[name]
+						if !ok {
+							continue
+						}
+						var problem string
+						switch {
+						case current.facts != nil:
+							if current.facts.HasPackageManager(name) {
+								continue
+							}
+							available := "no package manager"
+							if len(current.facts.PackageManagers) > 0 {
+								available = current.facts.PackageManagers[0]
+							}
+							problem = fmt.Sprintf("%s (%s has %s)", name, current.image, available)
+						case current.manager == "":
+							current.manager = name
+							continue
+						case osPackageManagers[current.manager] == distro:
+							continue
+						default:
+							problem = fmt.Sprintf("%s (stage uses %s)", name, current.manager)
+						}
+						found = append(found, problem)
+						validationContext := nodeContext.Context
+						validationContext.CausedFailure = true
+						if command.NameLocation != (docker.Location{}) {
+							validationContext.Locations = []docker.Location{command.NameLocation}
+						}
+						validationContexts = append(validationContexts, validationContext)
+					}
+				}
+
+				if len(found) == 0 {
+					return unevaluated.result(&validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					})
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Unavailable: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(runWithoutShell())
+	AddRule(packageManagerMismatch())
+}
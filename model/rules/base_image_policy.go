@@ -0,0 +1,302 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+)
+
+// defaultDeprecatedImages are official images which are deprecated or past end-of-life, see https://endoflife.date
+var defaultDeprecatedImages = []DeprecatedImage{
+	{Image: "python:<3.10", Replacement: "python:3"},
+	{Image: "node:<22", Replacement: "node:lts"},
+	{Image: "centos:*", Replacement: "almalinux or rockylinux"},
+	{Image: "openjdk:*", Replacement: "eclipse-temurin"},
+	{Image: "debian:<12", Replacement: "debian:bookworm"},
+	{Image: "debian:jessie", Replacement: "debian:bookworm"},
+	{Image: "debian:stretch", Replacement: "debian:bookworm"},
+	{Image: "debian:buster", Replacement: "debian:bookworm"},
+	{Image: "debian:bullseye", Replacement: "debian:bookworm"},
+	{Image: "ubuntu:<22.04", Replacement: "ubuntu:24.04"},
+	{Image: "ubuntu:xenial", Replacement: "ubuntu:noble"},
+	{Image: "ubuntu:bionic", Replacement: "ubuntu:noble"},
+	{Image: "ubuntu:focal", Replacement: "ubuntu:noble"},
+	{Image: "alpine:<3.21", Replacement: "alpine:3"},
+}
+
+// matchesImagePattern determines whether ref matches pattern (see BaseImageSettings)
+func matchesImagePattern(pattern string, ref docker.ImageReference) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	for _, name := range []string{ref.Name(), ref.FullName()} {
+		if matched, _ := path.Match(pattern, name); matched || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// versionOf parses the leading dotted numeric version of a tag (3.12 of 3.12-slim), or nil if the tag doesn't start with a version
+func versionOf(tag string) []int {
+	version := make([]int, 0)
+	for _, segment := range strings.Split(tag, ".") {
+		digits := segment
+		if idx := strings.IndexFunc(segment, func(r rune) bool { return r < '0' || r > '9' }); idx >= 0 {
+			digits = segment[:idx]
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			break
+		}
+		version = append(version, n)
+		if len(digits) < len(segment) {
+			break
+		}
+	}
+	if len(version) == 0 {
+		return nil
+	}
+	return version
+}
+
+// isOlderVersion determines whether the version of tag is less than bound. Tags which omit trailing segments of bound
+// float to the latest release (python:3 isn't older than 3.10), so are not considered older unless a segment is.
+func isOlderVersion(tag string, bound string, inclusive bool) bool {
+	version, limit := versionOf(tag), versionOf(bound)
+	if version == nil || limit == nil {
+		return false
+	}
+	for i, n := range limit {
+		if i >= len(version) {
+			return false
+		}
+		if version[i] != n {
+			return version[i] < n
+		}
+	}
+	return inclusive
+}
+
+// matches determines whether ref is the deprecated image
+func (d DeprecatedImage) matches(ref docker.ImageReference) bool {
+	repository, tag := d.Image, ""
+	if idx := strings.LastIndex(d.Image, ":"); idx > strings.LastIndex(d.Image, "/") {
+		repository, tag = d.Image[:idx], d.Image[idx+1:]
+	}
+	if !matchesImagePattern(repository, ref) {
+		return false
+	}
+	switch {
+	case tag == "" || tag == "*":
+		return true
+	case ref.Tag == "":
+		return false
+	case strings.HasPrefix(tag, "<="):
+		return isOlderVersion(ref.Tag, strings.TrimPrefix(tag, "<="), true)
+	case strings.HasPrefix(tag, "<"):
+		return isOlderVersion(ref.Tag, strings.TrimPrefix(tag, "<"), false)
+	case strings.ContainsAny(tag, "*?["):
+		matched, _ := path.Match(tag, ref.Tag)
+		return matched
+	default:
+		return ref.Tag == tag || strings.HasPrefix(ref.Tag, tag+".") || strings.HasPrefix(ref.Tag, tag+"-")
+	}
+}
+
+// supports determines whether ref is excluded from the built-in list of deprecated images
+func (s BaseImageSettings) supports(ref docker.ImageReference) bool {
+	for _, image := range s.Supported {
+		if (DeprecatedImage{Image: image}).matches(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseImageCheck evaluates the image of a FROM instruction against the policy, returning a description of any violation
+type baseImageCheck func(ref docker.ImageReference, nodeContext validations.NodeValidationContext) (string, bool)
+
+// evaluate applies the check to the base image of each stage, excluding scratch, references to earlier stages, and
+// images which can't be resolved without build arguments
+func (check baseImageCheck) evaluate(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	if mcr == nil || mcr.ContextCache == nil {
+		return validations.NewValidationResultSkipped(mcr.GetSummary())
+	}
+
+	stageNames := make(map[string]bool)
+	violations := make([]string, 0)
+	validationContexts := make([]validations.ValidationContext, 0)
+	for _, nodeContext := range *mcr.ContextCache {
+		result := processFrom(&nodeContext.Node, nodeContext.Context, func(image string, builderName *string) *validations.ValidationResult {
+			defer func() {
+				if builderName != nil {
+					stageNames[strings.ToLower(*builderName)] = true
+				}
+			}()
+			if image == "scratch" || stageNames[strings.ToLower(image)] || strings.Contains(image, "$") {
+				return validations.NewValidationResultSkipped(mcr.GetSummary())
+			}
+			ref, err := docker.ParseImageReference(image)
+			if err != nil {
+				return validations.NewValidationResultSkipped(err.Error())
+			}
+			if violation, failed := check(ref, nodeContext); failed {
+				return &validations.ValidationResult{Result: model.Failure, Details: violation}
+			}
+			return &validations.ValidationResult{Result: model.Success, Details: mcr.GetSummary()}
+		})
+		if result.Result != model.Failure {
+			continue
+		}
+		violations = append(violations, result.Details)
+		validationContext := nodeContext.Context
+		validationContext.CausedFailure = true
+		validationContexts = append(validationContexts, validationContext)
+	}
+
+	if len(violations) == 0 {
+		return &validations.ValidationResult{
+			Result:  model.Success,
+			Details: mcr.GetSummary(),
+		}
+	}
+	return &validations.ValidationResult{
+		Result:   model.Failure,
+		Details:  fmt.Sprintf("%s Found: %s", mcr.GetSummary(), strings.Join(violations, ", ")),
+		Contexts: validationContexts,
+	}
+}
+
+func baseImageRegistry() validations.Rule {
+	check := baseImageCheck(func(ref docker.ImageReference, nodeContext validations.NodeValidationContext) (string, bool) {
+		policy := settingsOf(nodeContext.Context).BaseImages
+		for _, pattern := range policy.Deny {
+			if matchesImagePattern(pattern, ref) {
+				return fmt.Sprintf("%s (denied by %s)", ref, pattern), true
+			}
+		}
+		if len(policy.Allow) == 0 {
+			return "", false
+		}
+		for _, pattern := range policy.Allow {
+			if matchesImagePattern(pattern, ref) {
+				return "", false
+			}
+		}
+		return fmt.Sprintf("%s (not allowed)", ref), true
+	})
+	r := validations.MultiContextRule{
+		Name:    "base-image-registry",
+		Summary: "Base images should come from allowed registries and repositories",
+		Details: "Images from untrusted registries or repositories may be unmaintained or malicious. " +
+			"Configure settings.base_images.allow and settings.base_images.deny to enforce the registries and repositories approved by your organization.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/best-practices/#choose-the-right-base-image"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate,
+		},
+	}
+	return &r
+}
+
+func baseImageDigest() validations.Rule {
+	check := baseImageCheck(func(ref docker.ImageReference, nodeContext validations.NodeValidationContext) (string, bool) {
+		if !settingsOf(nodeContext.Context).BaseImages.RequireDigest || nodeContext.Context.IsBuilderContext || ref.Digest != "" {
+			return "", false
+		}
+		return ref.String(), true
+	})
+	r := validations.MultiContextRule{
+		Name:    "base-image-digest",
+		Summary: "The base image of the final stage should be pinned by digest",
+		Details: "Tags are mutable, so the image may change between builds. Pinning the final stage by digest (image:tag@sha256:...) makes builds reproducible. " +
+			"Enable via settings.base_images.require_digest.",
+		Priority: model.MediumPriority,
+		Commands: []commands.DockerCommand{commands.From},
+		URL:      model.StringPtr("https://docs.docker.com/build/building/best-practices/#pin-base-image-versions"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate,
+		},
+	}
+	return &r
+}
+
+func deprecatedBaseImage() validations.Rule {
+	check := baseImageCheck(func(ref docker.ImageReference, nodeContext validations.NodeValidationContext) (string, bool) {
+		settings := settingsOf(nodeContext.Context).BaseImages
+		deprecated := append([]DeprecatedImage{}, settings.Deprecated...)
+		if !settings.supports(ref) {
+			deprecated = append(deprecated, defaultDeprecatedImages...)
+		}
+		for _, image := range deprecated {
+			if !image.matches(ref) {
+				continue
+			}
+			if image.Replacement == "" {
+				return ref.String(), true
+			}
+			return fmt.Sprintf("%s (use %s)", ref, image.Replacement), true
+		}
+		return "", false
+	})
+	r := validations.MultiContextRule{
+		Name:    "deprecated-base-image",
+		Summary: "Avoid deprecated or end-of-life base images",
+		Details: "Deprecated and end-of-life images no longer receive security updates. " +
+			"Additional images may be configured via settings.base_images.deprecated, and images which are still supported " +
+			"(e.g. under extended support) may be excluded from the built-in list via settings.base_images.supported.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://endoflife.date"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate,
+		},
+	}
+	return &r
+}
+
+func baseImagePlatform() validations.Rule {
+	check := baseImageCheck(func(ref docker.ImageReference, nodeContext validations.NodeValidationContext) (string, bool) {
+		policy := settingsOf(nodeContext.Context).BaseImages
+		platform := instructionOf(&nodeContext.Node, nodeContext.Context).Flags.Platform
+		if platform == "" {
+			if policy.RequirePlatform {
+				return fmt.Sprintf("%s (missing --platform)", ref), true
+			}
+			return "", false
+		}
+		if len(policy.Platforms) == 0 || strings.Contains(platform, "$") || model.StringSliceContains(&policy.Platforms, platform) {
+			return "", false
+		}
+		return fmt.Sprintf("%s (--platform=%s not allowed)", ref, platform), true
+	})
+	r := validations.MultiContextRule{
+		Name:    "base-image-platform",
+		Summary: "Stages should build for an allowed platform",
+		Details: "Without --platform, the base image matches the platform of the build host, so images may differ between developer machines and CI. " +
+			"Configure settings.base_images.require_platform and settings.base_images.platforms to enforce platforms.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/multi-platform/"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate,
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(baseImageRegistry())
+	AddRule(baseImageDigest())
+	AddRule(deprecatedBaseImage())
+	AddRule(baseImagePlatform())
+}
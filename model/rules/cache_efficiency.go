@@ -0,0 +1,424 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+// layerCommands are the instructions which create filesystem layers, in the order they are reported
+var layerCommands = []commands.DockerCommand{commands.Run, commands.Copy, commands.Add}
+
+// dependencyManagers is the lookup of subcommands which install the dependencies declared by a project's manifest,
+// e.g. package.json, requirements.txt, Gemfile or go.mod
+var dependencyManagers = model.PredicateMap{
+	"npm":      isOneOf("install", "i", "ci"),
+	"pnpm":     isOneOf("install", "i"),
+	"yarn":     isOneOf("install"),
+	"pip":      isOneOf("install"),
+	"pip3":     isOneOf("install"),
+	"pipenv":   isOneOf("install", "sync"),
+	"poetry":   isOneOf("install"),
+	"bundle":   isOneOf("install"),
+	"composer": isOneOf("install"),
+	"go":       isOneOf("mod"),
+	"cargo":    isOneOf("fetch"),
+	"dotnet":   isOneOf("restore"),
+}
+
+// aptUpdates is the lookup of the apt subcommand which downloads package lists to /var/lib/apt/lists
+var aptUpdates = model.PredicateMap{"apt": isOneOf("update"), "apt-get": isOneOf("update")}
+
+// stageName is the name of a build stage as given to docker build --target, or its index for unnamed stages
+func stageName(stage *docker.Stage) string {
+	if stage.Name != "" {
+		return stage.Name
+	}
+	return fmt.Sprintf("stage %d", stage.Index)
+}
+
+// stageLayers finds the contexts of a stage which create layers in the stage's image. ONBUILD triggers create layers in downstream images.
+func stageLayers(stage []validations.NodeValidationContext) []validations.NodeValidationContext {
+	layers := make([]validations.NodeValidationContext, 0)
+	for _, nodeContext := range stage {
+		command := commands.Of(nodeContext.Node.Value)
+		if command == commands.Run || command == commands.Copy || command == commands.Add {
+			layers = append(layers, nodeContext)
+		}
+	}
+	return layers
+}
+
+// layerBreakdown describes the number of layers created by each instruction, e.g. 5 layers: 3 RUN, 2 COPY
+func layerBreakdown(layers []validations.NodeValidationContext) string {
+	counts := make(map[commands.DockerCommand]int)
+	for _, nodeContext := range layers {
+		command := commands.Of(nodeContext.Node.Value)
+		counts[command]++
+	}
+	parts := make([]string, 0)
+	for _, command := range layerCommands {
+		if counts[command] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[command], command.Upper()))
+		}
+	}
+	summary := fmt.Sprintf("%d layers", len(layers))
+	if len(layers) == 1 {
+		summary = "1 layer"
+	}
+	if len(parts) == 0 {
+		return summary
+	}
+	return fmt.Sprintf("%s: %s", summary, strings.Join(parts, ", "))
+}
+
+func minimizeLayers() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "minimize-layers",
+		Summary: "Try to minimize the number of layers which increase image size",
+		Details: "RUN, ADD, and COPY create new layers which may increase the size of the final image. " +
+			"Consider condensing these to fewer than 7 combined layers in the final stage or use multi-stage builds where possible. " +
+			"The number of layers in each build stage is reported.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.Add, commands.Copy},
+		URL:              model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#minimize-the-number-of-layers"),
+		AppliesToBuilder: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				stages := stagesOf(*mcr.ContextCache)
+				breakdown := make([]string, 0, len(stages))
+				var finalLayers []validations.NodeValidationContext
+				for _, stage := range stages {
+					layers := stageLayers(stage)
+					if len(layers) == 0 {
+						continue
+					}
+					breakdown = append(breakdown, fmt.Sprintf("%s (%s)", stageName(stage[0].Context.Stage), layerBreakdown(layers)))
+					if !stage[0].Context.IsBuilderContext {
+						finalLayers = layers
+					}
+				}
+				details := fmt.Sprintf("%s Found: %s", mcr.GetSummary(), strings.Join(breakdown, "; "))
+
+				// only the layers of the final stage contribute to the size of the image
+				if len(finalLayers) <= len(layerCommands)*2 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: details,
+					}
+				}
+
+				validationContexts := make([]validations.ValidationContext, 0, len(finalLayers))
+				for _, nodeContext := range finalLayers {
+					validationContext := nodeContext.Context
+					validationContext.HasRecommendations = true
+					validationContexts = append(validationContexts, validationContext)
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  details,
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// copiesSourceTree determines whether COPY or ADD copies the entire build context (COPY . . or COPY ./* /app)
+func copiesSourceTree(sourcesAndDest *instructions.SourcesAndDest) bool {
+	for _, source := range sourcesAndDest.SourcePaths {
+		if source == "*" || path.Clean(source) == "." || path.Clean(source) == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// installsDependencies determines whether command installs the dependencies declared by a manifest, returning the manager.
+// Installs of named packages (npm install -g serve, pip install flask) don't depend on the project's sources.
+func installsDependencies(command shell.PosixCommand) (string, bool) {
+	install, ok := findInstallCommand(command, dependencyManagers)
+	if !ok {
+		return "", false
+	}
+	switch install.Manager {
+	case "pip", "pip3":
+		for _, arg := range command.Args {
+			if arg == "-r" || strings.HasPrefix(arg, "--requirement") {
+				return install.Manager, true
+			}
+		}
+		return "", false
+	case "go":
+		return "go mod download", len(install.Packages) > 0 && install.Packages[0] == "download"
+	default:
+		return install.Manager, len(install.Packages) == 0
+	}
+}
+
+func copySourceBeforeInstall() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "copy-source-before-install",
+		Summary: "Copy dependency manifests and install dependencies before copying the rest of the source",
+		Details: "Copying the entire source tree invalidates the build cache of every later instruction whenever any file changes, " +
+			"so dependencies installed after COPY . . are downloaded again on every build. Copy only the manifests " +
+			"(e.g. package.json and package-lock.json, requirements.txt, go.mod and go.sum), install dependencies, then copy the rest of the source.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Copy, commands.Add, commands.Run},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/cache/optimize/#order-your-layers"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// findings by the line of their COPY or ADD instruction
+				findings := make(map[int][]scriptFinding)
+				unevaluated := unevaluatedRuns{}
+				for _, stage := range stagesOf(*mcr.ContextCache) {
+					var sourceCopy *validations.NodeValidationContext
+					for i := range stage {
+						nodeContext := &stage[i]
+						parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+						if copyCommand := parsed.Copy(); copyCommand != nil {
+							if sourceCopy == nil && copyCommand.From == "" && copiesSourceTree(&copyCommand.SourcesAndDest) {
+								sourceCopy = nodeContext
+							}
+							continue
+						}
+						if addCommand := parsed.Add(); addCommand != nil {
+							if sourceCopy == nil && copiesSourceTree(&addCommand.SourcesAndDest) {
+								sourceCopy = nodeContext
+							}
+							continue
+						}
+						if parsed.Run() == nil || sourceCopy == nil {
+							continue
+						}
+						posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+						if err != nil {
+							unevaluated.add(nodeContext.Context, err)
+							continue
+						}
+						for _, command := range posixCommands {
+							if manager, ok := installsDependencies(command); ok {
+								line := sourceCopy.Node.StartLine
+								findings[line] = append(findings[line], scriptFinding{
+									Text: fmt.Sprintf("%s before %s (line %d)", sourceCopy.Node.Original, manager, nodeContext.Node.StartLine),
+								})
+								// report each copy of the source once
+								sourceCopy = nil
+								break
+							}
+						}
+					}
+				}
+
+				return unevaluated.result(runFindings(mcr, model.Recommendation, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+					return findings[nodeContext.Node.StartLine]
+				}))
+			},
+		},
+	}
+	return &r
+}
+
+// createdPaths finds the paths written by a RUN command which are commonly removed afterwards: downloaded files,
+// cloned repositories and apt package lists
+func createdPaths(command shell.PosixCommand, workdir string) []string {
+	if _, ok := findInstallCommand(command, aptUpdates); ok {
+		return []string{"/var/lib/apt/lists"}
+	}
+	name := commandName(&command)
+	if downloader, ok := isDownload(&command); ok {
+		if file, writesFile := downloadOutput(downloader, command.Args); writesFile && file != "" && !isVariablePath(file) {
+			return []string{resolvePath(workdir, file)}
+		}
+		return nil
+	}
+	if name != "git" || len(command.Args) == 0 || command.Args[0] != "clone" {
+		return nil
+	}
+	// git clone [options] <repository> [<directory>]
+	operands := make([]string, 0)
+	for _, arg := range command.Args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			operands = append(operands, arg)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		repository := operands[0]
+		if parsed, err := url.Parse(repository); err == nil && parsed.Path != "" {
+			repository = parsed.Path
+		}
+		return []string{resolvePath(workdir, strings.TrimSuffix(path.Base(repository), ".git"))}
+	default:
+		if isVariablePath(operands[len(operands)-1]) {
+			return nil
+		}
+		return []string{resolvePath(workdir, operands[len(operands)-1])}
+	}
+}
+
+// removedPaths finds the paths removed by rm, which may be globs (rm -rf /var/lib/apt/lists/*)
+func removedPaths(command shell.PosixCommand, workdir string) []string {
+	if commandName(&command) != "rm" {
+		return nil
+	}
+	removed := make([]string, 0)
+	for _, arg := range command.Args {
+		if strings.HasPrefix(arg, "-") || isVariablePath(arg) {
+			continue
+		}
+		if target := resolvePath(workdir, arg); globBase(target) != "/" {
+			removed = append(removed, target)
+		}
+	}
+	return removed
+}
+
+// globBase is the directory of target which contains no glob characters
+func globBase(target string) string {
+	for strings.ContainsAny(target, "*?[") {
+		target = path.Dir(target)
+	}
+	return target
+}
+
+// removes determines whether removing target, which may be a glob, removes p or files within p
+func removes(target string, p string) bool {
+	return removesAll(target, p) || isWithin(globBase(target), p)
+}
+
+// removesAll determines whether removing target, which may be a glob, removes all of p
+func removesAll(target string, p string) bool {
+	base := globBase(target)
+	if base == target {
+		return isWithin(p, target)
+	}
+	for ancestor := p; ancestor != base && isWithin(ancestor, base); ancestor = path.Dir(ancestor) {
+		if matched, _ := path.Match(target, ancestor); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// createdPath is a path written by an earlier layer
+type createdPath struct {
+	path string
+	line int
+}
+
+// withoutRemoved filters the paths removed entirely by target from created
+func withoutRemoved(created []createdPath, target string) []createdPath {
+	remaining := make([]createdPath, 0, len(created))
+	for _, c := range created {
+		if !removesAll(target, c.path) {
+			remaining = append(remaining, c)
+		}
+	}
+	return remaining
+}
+
+func deleteInLaterLayer() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "delete-in-later-layer",
+		Summary: "Remove temporary files in the same layer which created them",
+		Details: "Files removed in a later layer remain in the layer which created them, so removing them doesn't reduce the size of the image. " +
+			"Download, extract and remove archives within a single RUN, remove apt package lists in the same RUN as apt-get update, " +
+			"and exclude unneeded files from COPY via .dockerignore.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.Copy, commands.Add, commands.Workdir},
+		AppliesToBuilder: false,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/best-practices/#run"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// findings by the line of their RUN instruction
+				findings := make(map[int][]scriptFinding)
+				unevaluated := unevaluatedRuns{}
+				workdir := "/"
+				created := make([]createdPath, 0)
+				for i := range *mcr.ContextCache {
+					nodeContext := &(*mcr.ContextCache)[i]
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					var sourcesAndDest *instructions.SourcesAndDest
+					if copyCommand := parsed.Copy(); copyCommand != nil {
+						sourcesAndDest = &copyCommand.SourcesAndDest
+					} else if addCommand := parsed.Add(); addCommand != nil {
+						sourcesAndDest = &addCommand.SourcesAndDest
+					}
+					switch {
+					case parsed.Workdir() != nil:
+						workdir = resolvePath(workdir, parsed.Workdir().Path)
+					case sourcesAndDest != nil:
+						if isVariablePath(sourcesAndDest.DestPath) {
+							continue
+						}
+						for _, file := range copiedFiles(sourcesAndDest.DestPath, sourcesAndDest.SourcePaths, workdir) {
+							created = append(created, createdPath{path: file, line: nodeContext.Node.StartLine})
+						}
+					case parsed.Run() != nil:
+						posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+						if err != nil {
+							unevaluated.add(nodeContext.Context, err)
+							continue
+						}
+						// paths created by this RUN may be removed within the same layer
+						layerCreated := make([]createdPath, 0)
+						for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+							for _, target := range removedPaths(command.PosixCommand, workdir) {
+								for _, earlier := range created {
+									if removes(target, earlier.path) {
+										findings[nodeContext.Node.StartLine] = append(findings[nodeContext.Node.StartLine], scriptFinding{
+											Location: commandSpan(command),
+											Text:     fmt.Sprintf("%s created on line %d", target, earlier.line),
+										})
+									}
+								}
+								created = withoutRemoved(created, target)
+								layerCreated = withoutRemoved(layerCreated, target)
+							}
+							for _, p := range createdPaths(command.PosixCommand, workdir) {
+								layerCreated = append(layerCreated, createdPath{path: p, line: nodeContext.Node.StartLine})
+							}
+						}
+						created = append(created, layerCreated...)
+					}
+				}
+
+				return unevaluated.result(runFindings(mcr, model.Recommendation, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+					return findings[nodeContext.Node.StartLine]
+				}))
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(minimizeLayers())
+	AddRule(copySourceBeforeInstall())
+	AddRule(deleteInLaterLayer())
+}
@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// hasMountType determines whether the RUN instruction defines a --mount of the given type
+func hasMountType(parsed *docker.ParsedInstruction, mountType instructions.MountType) bool {
+	for _, mount := range parsed.Flags.Mounts {
+		if mount != nil && mount.Type == mountType {
+			return true
+		}
+	}
+	return false
+}
+
+func packageCacheMount() validations.Rule {
+	commandLookup := installIndicators()
+	r := validations.MultiContextRule{
+		Name:    "package-cache-mount",
+		Summary: "Consider using a cache mount for package manager installs",
+		Details: "RUN --mount=type=cache keeps package manager caches (e.g. /var/cache/apt, /root/.npm) between builds " +
+			"without persisting them in the image layer. This speeds up rebuilds and keeps images small.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/cache/optimize/#use-cache-mounts"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				if !validationContext.Directives.Supports(docker.FeatureRunMount) {
+					return model.Skipped
+				}
+
+				parsed := instructionOf(node, validationContext)
+				if hasMountType(parsed, instructions.MountTypeCache) {
+					return model.Success
+				}
+
+				posixCommands, err := runCommands(node, validationContext)
+				if err != nil {
+					return model.Skipped
+				}
+				for _, command := range posixCommands {
+					if _, ok := findInstallCommand(command, commandLookup); ok {
+						return model.Recommendation
+					}
+				}
+				return model.Success
+			},
+			Unevaluated: unevaluatedRun,
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(packageCacheMount())
+}
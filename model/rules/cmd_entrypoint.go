@@ -0,0 +1,309 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// commandLineOf returns the command line of a CMD or ENTRYPOINT instruction, or nil for other instructions
+func commandLineOf(node *parser.Node, validationContext validations.ValidationContext) *instructions.ShellDependantCmdLine {
+	parsed := instructionOf(node, validationContext)
+	if cmd := parsed.Cmd(); cmd != nil {
+		return &cmd.ShellDependantCmdLine
+	}
+	if entrypoint := parsed.Entrypoint(); entrypoint != nil {
+		return &entrypoint.ShellDependantCmdLine
+	}
+	return nil
+}
+
+// isMalformedExecForm determines whether the arguments of node look like an exec form (JSON) array,
+// but fail to parse as JSON and are therefore run via the shell.
+func isMalformedExecForm(node *parser.Node) bool {
+	if node.Attributes["json"] || node.Next == nil {
+		return false
+	}
+	text := strings.TrimSpace(node.Next.Value)
+	return strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]")
+}
+
+func preferExecForm() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "prefer-exec-form",
+		Summary: "Use the exec (JSON) form for CMD and ENTRYPOINT",
+		Details: "In shell form, the command runs as a child of /bin/sh -c, which doesn't forward signals. " +
+			"The process won't receive SIGTERM on docker stop and is killed after the timeout, preventing a graceful shutdown.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Cmd, commands.Entrypoint},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/build-checks/json-args-recommended/"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				commandLine := commandLineOf(node, validationContext)
+				if commandLine != nil && commandLine.PrependShell {
+					return model.Recommendation
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func malformedExecForm() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "malformed-exec-form",
+		Summary: "CMD and ENTRYPOINT arrays must be valid JSON",
+		Details: "An array which isn't valid JSON, such as one using single quotes or a trailing comma, is silently run as a shell command, " +
+			"brackets and all. Use double quotes and separate each argument with a comma.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Cmd, commands.Entrypoint},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#exec-form"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				if isMalformedExecForm(node) {
+					return model.Failure
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func singleEntrypoint() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:     "single-entrypoint",
+		Summary:  "Only a single ENTRYPOINT instruction is supported",
+		Details:  "Only the last ENTRYPOINT of the final stage takes effect. Earlier entrypoints are ignored, which may indicate a programming error.",
+		Priority: model.HighPriority,
+		Commands: []commands.DockerCommand{commands.Entrypoint},
+		URL:      model.StringPtr("https://docs.docker.com/reference/dockerfile/#entrypoint"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					validationContexts = append(validationContexts, nodeContext.Context)
+				}
+
+				if len(validationContexts) < 2 {
+					return &validations.ValidationResult{
+						Result:   model.Success,
+						Details:  mcr.GetSummary(),
+						Contexts: validationContexts,
+					}
+				}
+				// all but the last are overridden
+				for i := 0; i < len(validationContexts)-1; i++ {
+					validationContexts[i].CausedFailure = true
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  mcr.GetSummary(),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func shellEntrypointIgnoresCmd() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "shell-entrypoint-ignores-cmd",
+		Summary: "A shell form ENTRYPOINT ignores CMD",
+		Details: "When ENTRYPOINT uses the shell form, CMD and any arguments passed to docker run are not passed to the entrypoint. " +
+			"Use the exec (JSON) form of ENTRYPOINT so CMD provides its default arguments.",
+		Priority: model.HighPriority,
+		Commands: []commands.DockerCommand{commands.Entrypoint, commands.Cmd},
+		URL:      model.StringPtr("https://docs.docker.com/reference/dockerfile/#understand-how-cmd-and-entrypoint-interact"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				var entrypoint *validations.ValidationContext
+				var shellForm bool
+				cmdContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					commandLine := commandLineOf(&nodeContext.Node, nodeContext.Context)
+					if commands.Of(nodeContext.Node.Value) == commands.Entrypoint {
+						validationContext := nodeContext.Context
+						entrypoint = &validationContext
+						shellForm = commandLine != nil && commandLine.PrependShell
+						continue
+					}
+					cmdContexts = append(cmdContexts, nodeContext.Context)
+				}
+
+				if entrypoint == nil || !shellForm || len(cmdContexts) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				entrypoint.CausedFailure = true
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  mcr.GetSummary(),
+					Contexts: append([]validations.ValidationContext{*entrypoint}, cmdContexts...),
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// executableOf returns the program run by a CMD or ENTRYPOINT. Where a shell runs a script (e.g. sh /entrypoint.sh),
+// the script is returned.
+func executableOf(commandLine *instructions.ShellDependantCmdLine, activeShell []string) string {
+	if commandLine == nil || len(commandLine.CmdLine) == 0 {
+		return ""
+	}
+	args := commandLine.CmdLine
+	if commandLine.PrependShell {
+		posixCommands, err := shell.NewPosixCommandWithShell(strings.Join(args, " "), activeShell)
+		if err != nil || len(posixCommands) == 0 {
+			return ""
+		}
+		args = append([]string{posixCommands[0].Name}, posixCommands[0].Args...)
+	}
+	executable := args[0]
+	if _, err := shell.Variant(args[:1]); err == nil {
+		for _, arg := range args[1:] {
+			if arg == "-c" {
+				return ""
+			}
+			if !strings.HasPrefix(arg, "-") {
+				return arg
+			}
+		}
+	}
+	return executable
+}
+
+// isRootOwner determines whether the owner of COPY --chown (user[:group]) is the root user, where empty is root
+func isRootOwner(chown string) bool {
+	owner, _, _ := strings.Cut(chown, ":")
+	return owner == "" || owner == "root" || owner == "0"
+}
+
+// resolvePath resolves p against the directory workdir, unless p is absolute
+func resolvePath(workdir string, p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(workdir, p)
+}
+
+// copiedFiles returns the absolute paths of the files written by COPY or ADD, relative to workdir
+func copiedFiles(destination string, sources []string, workdir string) []string {
+	if len(sources) == 1 && !strings.HasSuffix(destination, "/") && destination != "." {
+		return []string{resolvePath(workdir, destination)}
+	}
+	destination = resolvePath(workdir, destination)
+	files := make([]string, 0, len(sources))
+	for _, source := range sources {
+		files = append(files, path.Join(destination, path.Base(source)))
+	}
+	return files
+}
+
+func rootOwnedEntrypoint() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "root-owned-entrypoint",
+		Summary: "CMD and ENTRYPOINT scripts should be owned by root",
+		Details: "A script copied with --chown to a non-root user may be modified by that user at runtime, e.g. by a compromised process, " +
+			"and run again on restart. Leave executables owned by root, granting the runtime user read and execute permissions only.",
+		Priority: model.MediumPriority,
+		Commands: []commands.DockerCommand{commands.Entrypoint, commands.Cmd, commands.Copy, commands.Add, commands.Workdir},
+		URL:      model.StringPtr("https://docs.docker.com/reference/dockerfile/#copy---chown---chmod"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				workdir := "/"
+				owners := make(map[string]string)
+				copyContexts := make(map[string]validations.ValidationContext)
+				executables := make(map[commands.DockerCommand]string)
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					var sourcesAndDest *instructions.SourcesAndDest
+					if copyCommand := parsed.Copy(); copyCommand != nil {
+						sourcesAndDest = &copyCommand.SourcesAndDest
+					} else if addCommand := parsed.Add(); addCommand != nil {
+						sourcesAndDest = &addCommand.SourcesAndDest
+					}
+					switch {
+					case parsed.Workdir() != nil:
+						workdir = resolvePath(workdir, parsed.Workdir().Path)
+					case sourcesAndDest != nil:
+						for _, file := range copiedFiles(sourcesAndDest.DestPath, sourcesAndDest.SourcePaths, workdir) {
+							owners[file] = parsed.Flags.Chown
+							copyContexts[file] = nodeContext.Context
+						}
+					default:
+						executable := executableOf(commandLineOf(&nodeContext.Node, nodeContext.Context), nodeContext.Context.Shell)
+						if strings.Contains(executable, "/") {
+							// executables without a directory are found via PATH
+							executable = resolvePath(workdir, executable)
+						}
+						executables[commands.Of(nodeContext.Node.Value)] = executable
+					}
+				}
+
+				found := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, command := range []commands.DockerCommand{commands.Entrypoint, commands.Cmd} {
+					executable := executables[command]
+					owner, copied := owners[executable]
+					if !copied || isRootOwner(owner) || model.StringSliceContains(&found, executable) {
+						continue
+					}
+					found = append(found, executable)
+					validationContext := copyContexts[executable]
+					validationContext.CausedFailure = true
+					validationContexts = append(validationContexts, validationContext)
+				}
+
+				if len(found) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Not owned by root: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(preferExecForm())
+	AddRule(malformedExecForm())
+	AddRule(singleEntrypoint())
+	AddRule(shellEntrypointIgnoresCmd())
+	AddRule(rootOwnedEntrypoint())
+}
@@ -5,9 +5,7 @@ import (
 
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
-	"github.com/jimschubert/docked/model/shell"
 	"github.com/jimschubert/docked/model/validations"
-	log "github.com/sirupsen/logrus"
 )
 
 func considerMultistageBuild() validations.Rule {
@@ -25,16 +23,15 @@ func considerMultistageBuild() validations.Rule {
 				var hasFailures bool
 				var hasAnyBuilder bool
 				validationContexts := make([]validations.ValidationContext, 0)
+				unevaluated := unevaluatedRuns{}
 				for _, nodeContext := range *mcr.ContextCache {
 					if nodeContext.Context.IsBuilderContext {
 						hasAnyBuilder = true
 					}
 					if commands.Of(nodeContext.Node.Value) == commands.Run {
-						trimStart := len(nodeContext.Node.Value) + 1 // command plus trailing space
-						commandText := nodeContext.Node.Original[trimStart:]
-						posixCommands, err := shell.NewPosixCommand(commandText)
+						posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
 						if err != nil {
-							log.Warnf("Unable to parse RUN command, validation not evaluated for: %s", commandText)
+							unevaluated.add(nodeContext.Context, err)
 						} else {
 							for _, tool := range buildTools {
 								re := regexp.MustCompile(tool)
@@ -66,11 +63,11 @@ func considerMultistageBuild() validations.Rule {
 					}
 				}
 
-				return &validations.ValidationResult{
+				return unevaluated.result(&validations.ValidationResult{
 					Result:   result,
 					Details:  details,
 					Contexts: validationContexts,
-				}
+				})
 			},
 		},
 	}
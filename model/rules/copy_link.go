@@ -0,0 +1,43 @@
+package rules
+
+import (
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func copyLink() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "copy-link",
+		Summary: "Consider using COPY --link",
+		Details: "COPY --link copies files into an independent layer, so the copied layer can be reused even when earlier layers change. " +
+			"Avoid --link only if the destination relies on symlinks in previous layers.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Copy},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#copy---link"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				if !validationContext.Directives.Supports(docker.FeatureCopyLink) {
+					return model.Skipped
+				}
+				parsed := instructionOf(node, validationContext)
+				if parsed.Err != nil {
+					return model.Skipped
+				}
+				if !parsed.Flags.Link {
+					return model.Recommendation
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(copyLink())
+}
@@ -0,0 +1,145 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+)
+
+// sensitiveDirectories are directories holding credentials or repository history, wherever they appear in a path
+var sensitiveDirectories = []string{".aws", ".ssh", ".git", ".gnupg", ".kube", ".docker", ".azure", ".config/gcloud"}
+
+// sensitiveFiles are patterns, compatible with path.Match, for the base names of files which commonly hold credentials
+var sensitiveFiles = []string{
+	".env", ".env.*", "*.env",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	".npmrc", ".pypirc", ".netrc", ".git-credentials", ".htpasswd",
+	"credentials.json", "*.tfstate", "*.tfvars", "*.kdbx",
+}
+
+// sensitiveTemplates are patterns for the base names of templates of sensitive files, which hold no secrets
+var sensitiveTemplates = []string{"*.example", "*.sample", "*.template", "*.dist", "*.pub"}
+
+// matchesAny determines whether name matches one of patterns, via path.Match
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isSensitivePath determines whether the slash-separated path (or glob pattern) refers to a sensitive file or directory
+func isSensitivePath(p string) bool {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return false
+	}
+	for _, directory := range sensitiveDirectories {
+		if p == directory || strings.HasPrefix(p, directory+"/") || strings.Contains(p, "/"+directory+"/") || strings.HasSuffix(p, "/"+directory) {
+			return true
+		}
+	}
+	base := path.Base(p)
+	return matchesAny(base, sensitiveFiles) && !matchesAny(base, sensitiveTemplates)
+}
+
+// sensitiveFilesOf returns the sensitive paths brought in by source. When the build context is known, source is expanded
+// against it and the topmost sensitive paths are returned; otherwise, only source itself is checked against isSensitivePath and ignore.
+func sensitiveFilesOf(source string, buildContext *docker.BuildContext, ignore *docker.DockerIgnore) []string {
+	found := make([]string, 0)
+	if buildContext == nil {
+		if isSensitivePath(source) && !ignore.Ignores(source) {
+			found = append(found, source)
+		}
+		return found
+	}
+	for _, file := range buildContext.Files(source) {
+		if len(found) > 0 && strings.HasPrefix(file, found[len(found)-1]+"/") {
+			continue
+		}
+		if isSensitivePath(file) {
+			found = append(found, file)
+		}
+	}
+	return found
+}
+
+func copySensitiveFile() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "copy-sensitive-file",
+		Summary: "Avoid copying credentials, keys, or repository history into the image",
+		Details: "Files such as .env, id_rsa, *.pem, .npmrc, .aws/ and .git/ are persisted in the image layer, even if removed later. " +
+			"Exclude them via .dockerignore and use RUN --mount=type=secret or --mount=type=ssh for credentials needed while building. " +
+			"When the build context is known, sources are expanded against it to find sensitive files within copied directories.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Copy, commands.Add},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/secrets/"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				sensitive := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					var sources []string
+					if copyCommand := parsed.Copy(); copyCommand != nil && copyCommand.From == "" {
+						sources = copyCommand.SourcePaths
+					} else if addCommand := parsed.Add(); addCommand != nil {
+						sources = addCommand.SourcePaths
+					}
+
+					buildContext, ignore := nodeContext.Context.BuildContext, nodeContext.Context.DockerIgnore
+					cursor := docker.Position{}
+					for _, source := range sources {
+						location, located := nodeContext.Context.Source.Find(source, cursor)
+						if located {
+							cursor = location.End
+						}
+						if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+							continue
+						}
+						for _, file := range sensitiveFilesOf(source, buildContext, ignore) {
+							sensitive = append(sensitive, file)
+							validationContext := nodeContext.Context
+							validationContext.Line = file
+							validationContext.CausedFailure = true
+							if located {
+								validationContext.Locations = []docker.Location{location}
+							}
+							validationContexts = append(validationContexts, validationContext)
+						}
+					}
+				}
+
+				if len(sensitive) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Sensitive: %s", mcr.GetSummary(), strings.Join(sensitive, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(copySensitiveFile())
+}
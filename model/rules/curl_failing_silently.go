@@ -3,10 +3,8 @@ package rules
 import (
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
-	"github.com/jimschubert/docked/model/shell"
 	"github.com/jimschubert/docked/model/validations"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
-	log "github.com/sirupsen/logrus"
 )
 
 func curlWithoutFail() validations.Rule {
@@ -18,13 +16,13 @@ func curlWithoutFail() validations.Rule {
 		Priority:         model.CriticalPriority,
 		Commands:         []commands.DockerCommand{commands.Run},
 		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
 		Category:         nil,
 		URL:              model.StringPtr("https://curl.se/docs/faq.html#Why_do_I_get_downloaded_data_eve"),
 		Evaluator: validations.MultiContextPerNodeEvaluator{
 			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
-				posixCommands, err := shell.NewPosixCommandFromNode(node)
+				posixCommands, err := runCommands(node, validationContext)
 				if err != nil {
-					log.Warnf("Unable to parse RUN command, skipping validation: %#v", node.Location())
 					return model.Skipped
 				}
 				for _, command := range posixCommands {
@@ -45,6 +43,7 @@ func curlWithoutFail() validations.Rule {
 				}
 				return model.Success
 			},
+			Unevaluated: unevaluatedRun,
 		},
 	}
 	return &r
@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// knownSyntaxFrontends are the repositories of Docker's published Dockerfile frontends
+var knownSyntaxFrontends = []string{"docker/dockerfile", "docker/dockerfile-upstream"}
+
+// fileDirectives gets the parser directives from the first cached context, as directives apply to the whole Dockerfile
+func fileDirectives(mcr *validations.MultiContextRule) *docker.Directives {
+	if mcr == nil || mcr.ContextCache == nil || len(*mcr.ContextCache) == 0 {
+		return nil
+	}
+	return (*mcr.ContextCache)[0].Context.Directives
+}
+
+func syntaxDirective() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "syntax-directive",
+		Summary: "Use a known, pinned frontend image in the syntax directive",
+		Details: "The syntax directive selects the image used to build the Dockerfile. " +
+			"An untagged or latest image changes the build behavior without any change to the Dockerfile, and an unknown image runs arbitrary code during the build.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#syntax"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				directives := fileDirectives(mcr)
+				if directives == nil || directives.Syntax == nil {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+
+				syntax := directives.Syntax
+				context := validations.ValidationContext{Line: "# syntax=" + syntax.Image, Locations: syntax.Locations}
+				result := model.Success
+				details := mcr.GetSummary()
+				switch {
+				case syntax.Digest == "" && (syntax.Tag == "" || syntax.Tag == "latest"):
+					result = model.Failure
+					context.CausedFailure = true
+					details = fmt.Sprintf("The syntax image %s is not pinned to a version or digest", syntax.Image)
+				case !model.StringSliceContains(&knownSyntaxFrontends, syntax.Repository):
+					result = model.Recommendation
+					context.HasRecommendations = true
+					details = fmt.Sprintf("The syntax image %s is not a known Dockerfile frontend", syntax.Image)
+				}
+
+				return &validations.ValidationResult{
+					Result:   result,
+					Details:  details,
+					Contexts: []validations.ValidationContext{context},
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func invalidDirective() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "invalid-directive",
+		Summary: "Parser directives must be valid and precede all comments, blank lines, and instructions",
+		Details: "Parser directives are only recognized at the top of a Dockerfile. Anywhere else, they are treated as comments and silently ignored, " +
+			"so the intended escape character, syntax, or checks are not applied.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#parser-directives"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				directives := fileDirectives(mcr)
+				if directives == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				problems := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, directive := range directives.Misplaced {
+					problems = append(problems, fmt.Sprintf("%s directive on line %d is ignored", directive.Name, directive.Locations[0].Start.Line))
+					validationContexts = append(validationContexts, validations.ValidationContext{
+						Line:          directive.Original,
+						Locations:     directive.Locations,
+						CausedFailure: true,
+					})
+				}
+				if directives.Check != nil {
+					if _, err := linter.ParseLintOptions(directives.Check.Value); err != nil {
+						problems = append(problems, fmt.Sprintf("check directive is invalid: %s", err))
+						validationContexts = append(validationContexts, validations.ValidationContext{
+							Line:          directives.Check.Original,
+							Locations:     directives.Check.Locations,
+							CausedFailure: true,
+						})
+					}
+				}
+
+				if len(problems) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  strings.Join(problems, "; "),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func unsupportedSyntaxFeature() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "unsupported-syntax-feature",
+		Summary: "Instruction uses a feature unavailable in the pinned syntax version",
+		Details: "The syntax directive pins a docker/dockerfile version which predates a flag or heredoc used by this instruction, and the build will fail. " +
+			"Update the syntax directive, for example to docker/dockerfile:1.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.Copy, commands.Add},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/buildkit/dockerfile-release-notes/"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				features := instructionOf(node, validationContext).Features()
+				if len(validationContext.Directives.Unsupported(features)) > 0 {
+					return model.Failure
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(syntaxDirective())
+	AddRule(invalidDirective())
+	AddRule(unsupportedSyntaxFeature())
+}
@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+)
+
+// commonSensitivePaths are expected to be ignored when the build context isn't known
+var commonSensitivePaths = []string{".git", ".env"}
+
+// unignoredSensitivePaths returns the sensitive paths which the ignore file doesn't exclude. When the build context is
+// known, these are the sensitive paths which exist within it; otherwise, commonSensitivePaths.
+func unignoredSensitivePaths(validationContext validations.ValidationContext) []string {
+	candidates := commonSensitivePaths
+	if buildContext := validationContext.BuildContext; buildContext != nil {
+		candidates = sensitiveFilesOf(".", &docker.BuildContext{Dir: buildContext.Dir}, nil)
+	}
+	unignored := make([]string, 0)
+	for _, candidate := range candidates {
+		if !validationContext.DockerIgnore.Ignores(candidate) {
+			unignored = append(unignored, candidate)
+		}
+	}
+	return unignored
+}
+
+// copiesDirectory determines whether the COPY or ADD instruction may bring in many files of the build context,
+// via a directory or glob pattern source
+func copiesDirectory(parsed *docker.ParsedInstruction, buildContext *docker.BuildContext) bool {
+	var sources []string
+	if copyCommand := parsed.Copy(); copyCommand != nil && copyCommand.From == "" {
+		sources = copyCommand.SourcePaths
+	} else if addCommand := parsed.Add(); addCommand != nil {
+		sources = addCommand.SourcePaths
+	}
+	for _, source := range sources {
+		if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+			continue
+		}
+		if source == "." || strings.HasSuffix(source, "/") || strings.ContainsAny(source, "*?[") {
+			return true
+		}
+		if files := buildContext.Files(source); len(files) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func strictDockerignore() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "strict-dockerignore",
+		Summary: "Use a .dockerignore which excludes version control and secrets from the build context",
+		Details: "Copying directories brings in everything not excluded by .dockerignore (or Dockerfile.dockerignore), " +
+			"which may include .git, .env, and credentials. Exclude these, or use an allowlist (e.g. * followed by !src). " +
+			"An ignore file is recommended even when the build context holds no such files, as it also excludes those added later.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Copy, commands.Add},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/concepts/context/#dockerignore-files"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				var ignore *docker.DockerIgnore
+				var unignored []string
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					if !copiesDirectory(instructionOf(&nodeContext.Node, nodeContext.Context), nodeContext.Context.BuildContext) {
+						continue
+					}
+					if unignored == nil {
+						ignore = nodeContext.Context.DockerIgnore
+						unignored = unignoredSensitivePaths(nodeContext.Context)
+					}
+					validationContext := nodeContext.Context
+					validationContext.HasRecommendations = true
+					validationContexts = append(validationContexts, validationContext)
+				}
+
+				if len(validationContexts) == 0 || (ignore != nil && len(unignored) == 0) {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				details := fmt.Sprintf("%s No .dockerignore found.", mcr.GetSummary())
+				if ignore != nil {
+					details = fmt.Sprintf("%s Not ignored by %s: %s", mcr.GetSummary(), filepath.Base(ignore.Path), strings.Join(unignored, ", "))
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  details,
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(strictDockerignore())
+}
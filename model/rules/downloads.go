@@ -0,0 +1,359 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+)
+
+// downloaders are commands which download content from a URL
+var downloaders = []string{"curl", "wget"}
+
+// scriptInterpreters are commands which run a script read from stdin
+var scriptInterpreters = []string{"sh", "bash", "dash", "ash", "zsh", "ksh", "busybox", "python", "python3", "perl", "ruby", "node", "php"}
+
+// interpreterFlags are the short flags by which an interpreter runs a program given as an argument (python3 -c, perl -e),
+// those by which it explicitly reads its program from stdin (sh -s), and those taking a value (bash -o pipefail)
+var interpreterFlags = map[string]struct{ program, stdin, value string }{
+	"sh":      {program: "c", stdin: "s", value: "o"},
+	"bash":    {program: "c", stdin: "s", value: "o"},
+	"dash":    {program: "c", stdin: "s", value: "o"},
+	"ash":     {program: "c", stdin: "s", value: "o"},
+	"zsh":     {program: "c", stdin: "s", value: "o"},
+	"ksh":     {program: "c", stdin: "s", value: "o"},
+	"python":  {program: "cm", value: "WX"},
+	"python3": {program: "cm", value: "WX"},
+	"perl":    {program: "eE"},
+	"ruby":    {program: "e"},
+	"node":    {program: "ep", value: "r"},
+	"php":     {program: "r", value: "d"},
+}
+
+// archiveExtractors are commands which unpack a downloaded archive or package read from stdin
+var archiveExtractors = []string{"tar", "unzip", "gunzip", "bunzip2", "xz", "zstd", "cpio"}
+
+// checksumTools are commands which verify checksums with -c/--check (e.g. sha256sum -c app.tar.gz.sha256)
+var checksumTools = []string{"sha1sum", "sha224sum", "sha256sum", "sha384sum", "sha512sum", "md5sum", "b2sum", "shasum"}
+
+// commandName is the executable name of command, accounting for elevation and environment wrappers (sudo -E bash, env X=1 sh)
+func commandName(command *shell.PosixCommand) string {
+	name := path.Base(strings.TrimLeft(command.Name, `\`))
+	if name != "sudo" && name != "env" {
+		return name
+	}
+	for _, arg := range command.Args {
+		if strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+			continue
+		}
+		return path.Base(arg)
+	}
+	return name
+}
+
+// commandArgs are the arguments of command, excluding those of elevation and environment wrappers (sudo -E bash -s, env X=1 sh)
+func commandArgs(command *shell.PosixCommand) []string {
+	name := path.Base(strings.TrimLeft(command.Name, `\`))
+	if name != "sudo" && name != "env" {
+		return command.Args
+	}
+	for i, arg := range command.Args {
+		if strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+			continue
+		}
+		return command.Args[i+1:]
+	}
+	return nil
+}
+
+// executesStdin determines whether an interpreter runs its input as a program (sh, sh -s, python3 -), rather than running a program
+// given by a flag or script file which reads the input as data (python3 -c ..., python3 -m json.tool, node parse.js)
+func executesStdin(interpreter *shell.PosixCommand) bool {
+	name := commandName(interpreter)
+	args := commandArgs(interpreter)
+	if name == "busybox" && len(args) > 0 {
+		// busybox sh -s
+		name, args = path.Base(args[0]), args[1:]
+	}
+	flags := interpreterFlags[name]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-":
+			return true
+		case arg == "--eval" || arg == "--print" || strings.HasPrefix(arg, "--eval=") || strings.HasPrefix(arg, "--print="):
+			return false
+		case strings.HasPrefix(arg, "--"):
+			continue
+		case strings.HasPrefix(arg, "-"):
+			if flags.program != "" && strings.ContainsAny(arg[1:], flags.program) {
+				return false
+			}
+			if flags.stdin != "" && strings.ContainsAny(arg[1:], flags.stdin) {
+				return true
+			}
+			if flags.value != "" && strings.ContainsAny(arg[len(arg)-1:], flags.value) {
+				i++
+			}
+		default:
+			// the first operand is a script file, which reads the input as data
+			return false
+		}
+	}
+	return true
+}
+
+// isDownload determines whether command downloads content, returning the name of the downloader
+func isDownload(command *shell.PosixCommand) (string, bool) {
+	name := commandName(command)
+	return name, model.StringSliceContains(&downloaders, name)
+}
+
+// downloadOutput finds the file written by a download: curl writes to stdout unless -o/--output or -O/--remote-name is given,
+// while wget writes to a file unless -O - is given. The file is empty when it is named by a URL which can't be determined.
+func downloadOutput(name string, args []string) (string, bool) {
+	for i, arg := range args {
+		var value *string
+		switch {
+		case arg == "--":
+			// the remaining arguments are URLs
+			return remoteName(args[i+1:]), name == "wget"
+		case strings.HasPrefix(arg, "--"):
+			flag, v, hasValue := strings.Cut(arg, "=")
+			switch flag {
+			case "--remote-name", "--remote-name-all":
+				return remoteName(args), name == "curl"
+			case "--spider":
+				return "", false
+			case "--output", "--output-document":
+				if !hasValue && i+1 < len(args) {
+					v = args[i+1]
+				}
+				value = &v
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// short flags may be combined, where the value of the last flag may be attached or the next argument (-fsSLo app, -qO-)
+			output := "o"
+			if name == "wget" {
+				output = "O"
+			} else if strings.Contains(arg, "O") {
+				return remoteName(args), true
+			}
+			if idx := strings.Index(arg, output); idx > 0 {
+				v := arg[idx+1:]
+				if v == "" && i+1 < len(args) {
+					v = args[i+1]
+				}
+				value = &v
+			}
+		}
+		if value != nil {
+			return *value, *value != "-" && *value != "/dev/stdout"
+		}
+	}
+	return remoteName(args), name == "wget"
+}
+
+// remoteName is the name of the file downloaded from the last URL within args, as written by curl -O or wget
+func remoteName(args []string) string {
+	for i := len(args) - 1; i >= 0; i-- {
+		if !strings.Contains(args[i], "://") {
+			continue
+		}
+		parsed, err := url.Parse(args[i])
+		if err != nil {
+			return ""
+		}
+		if name := path.Base(parsed.Path); name != "." && name != "/" {
+			return name
+		}
+		return ""
+	}
+	return ""
+}
+
+// isPlainTextURL determines whether arg is a URL downloaded without TLS, excluding those of the local host
+func isPlainTextURL(arg string) bool {
+	lower := strings.ToLower(arg)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "ftp://") {
+		return false
+	}
+	parsed, err := url.Parse(arg)
+	if err != nil {
+		return true
+	}
+	host := parsed.Hostname()
+	return host != "localhost" && host != "127.0.0.1" && host != "::1"
+}
+
+// verifiesDownload determines whether command verifies the checksum or signature of a file
+func verifiesDownload(command *shell.PosixCommand) bool {
+	name := commandName(command)
+	switch {
+	case model.StringSliceContains(&checksumTools, name):
+		for _, arg := range command.Args {
+			if arg == "--check" || (strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c")) {
+				return true
+			}
+		}
+	case name == "gpg" || name == "gpg2":
+		return model.StringSliceContains(&command.Args, "--verify")
+	case name == "gpgv":
+		return true
+	case name == "cosign":
+		return len(command.Args) > 0 && strings.HasPrefix(command.Args[0], "verify")
+	case name == "minisign":
+		return model.StringSliceContains(&command.Args, "-V")
+	}
+	return false
+}
+
+// pipedTo finds the first command reading the output of command which is one of names
+func pipedTo(command *shell.PosixCommand, names []string) (string, bool) {
+	for _, reader := range command.Pipeline()[1:] {
+		if name := commandName(reader); model.StringSliceContains(&names, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// pipedToInterpreter finds the first command reading the output of command which runs it as a script
+func pipedToInterpreter(command *shell.PosixCommand) (string, bool) {
+	for _, reader := range command.Pipeline()[1:] {
+		if name := commandName(reader); model.StringSliceContains(&scriptInterpreters, name) && executesStdin(reader) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// downloadCheck finds problems with the downloads of a RUN instruction
+type downloadCheck func(commands []locatedCommand) []scriptFinding
+
+// evaluate applies the check to the commands of each RUN instruction, resulting in result for any findings
+func (check downloadCheck) evaluate(result model.Valid) func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	return func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+		unevaluated := unevaluatedRuns{}
+		return unevaluated.result(runFindings(mcr, result, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+			posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+			if err != nil {
+				unevaluated.add(nodeContext.Context, err)
+				return nil
+			}
+			return check(locateCommands(nodeContext.Context.Source, posixCommands))
+		}))
+	}
+}
+
+func pipeToShell() validations.Rule {
+	check := downloadCheck(func(located []locatedCommand) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		for _, command := range located {
+			downloader, ok := isDownload(&command.PosixCommand)
+			if !ok {
+				continue
+			}
+			if interpreter, piped := pipedToInterpreter(&command.PosixCommand); piped {
+				findings = append(findings, scriptFinding{Location: command.NameLocation, Text: fmt.Sprintf("%s | %s", downloader, interpreter)})
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "pipe-to-shell",
+		Summary: "Avoid piping downloaded scripts into a shell",
+		Details: "Scripts piped from a download (e.g. curl ... | sh) into a shell or interpreter run without any opportunity to review or verify them, " +
+			"and a partial download may run a truncated script. Download the script to a file, verify its checksum, then run it.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Failure),
+		},
+	}
+	return &r
+}
+
+func plainHTTPDownload() validations.Rule {
+	check := downloadCheck(func(located []locatedCommand) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		for _, command := range located {
+			if _, ok := isDownload(&command.PosixCommand); !ok {
+				continue
+			}
+			for i, arg := range command.Args {
+				if isPlainTextURL(arg) {
+					findings = append(findings, scriptFinding{Location: command.ArgLocations[i], Text: arg})
+				}
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:             "plain-http-download",
+		Summary:          "Download over HTTPS rather than plain HTTP",
+		Details:          "Content downloaded over http:// or ftp:// may be read or replaced in transit. Use https:// and verify the checksum of downloaded files.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://owasp.org/www-project-top-ten/2017/A3_2017-Sensitive_Data_Exposure"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Failure),
+		},
+	}
+	return &r
+}
+
+func downloadWithoutChecksum() validations.Rule {
+	check := downloadCheck(func(located []locatedCommand) []scriptFinding {
+		downloads := make([]scriptFinding, 0)
+		for _, command := range located {
+			if verifiesDownload(&command.PosixCommand) {
+				return nil
+			}
+			downloader, ok := isDownload(&command.PosixCommand)
+			if !ok {
+				continue
+			}
+			// scripts piped into an interpreter are evaluated by pipe-to-shell
+			if _, piped := pipedToInterpreter(&command.PosixCommand); piped {
+				continue
+			}
+			if extractor, piped := pipedTo(&command.PosixCommand, archiveExtractors); piped {
+				downloads = append(downloads, scriptFinding{Location: command.NameLocation, Text: fmt.Sprintf("%s | %s", downloader, extractor)})
+			} else if _, writesFile := downloadOutput(downloader, command.Args); writesFile {
+				downloads = append(downloads, scriptFinding{Location: command.NameLocation, Text: downloader})
+			}
+		}
+		return downloads
+	})
+	r := validations.MultiContextRule{
+		Name:    "download-without-checksum",
+		Summary: "Verify the checksum or signature of downloaded files",
+		Details: "Files downloaded without verification may have been tampered with or corrupted. Verify downloads in the same RUN, " +
+			`e.g. echo "${SHA256}  app.tar.gz" | sha256sum -c -, or gpg --verify app.tar.gz.asc app.tar.gz.`,
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/best-practices/#add-or-copy"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(pipeToShell())
+	AddRule(plainHTTPDownload())
+	AddRule(downloadWithoutChecksum())
+}
@@ -0,0 +1,308 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/catalog"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// variableReference matches $NAME and ${NAME...} references, along with any preceding escape characters and ${} modifiers
+var variableReference = regexp.MustCompile(`([\\` + "`" + `]*)\$(?:\{([A-Za-z_][A-Za-z0-9_]*)([^}]*)}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// singleQuoted matches single-quoted text, in which variables are not expanded
+var singleQuoted = regexp.MustCompile(`'[^']*'`)
+
+// inheritedEnvironment are variables commonly defined by base images or the runtime, which are not reported as undefined
+var inheritedEnvironment = []string{"PATH", "HOME", "HOSTNAME", "TERM", "LANG", "LC_ALL", "PWD", "SHELL", "USER"}
+
+// referencedVariables returns the names of variables expanded by value without a default (e.g. ${NAME:-default})
+func referencedVariables(value string, escapeToken rune) []string {
+	names := make([]string, 0)
+	for _, match := range variableReference.FindAllStringSubmatch(singleQuoted.ReplaceAllString(value, ""), -1) {
+		if strings.Count(match[1], string(escapeToken))%2 == 1 {
+			continue
+		}
+		if match[2] != "" {
+			if match[3] == "" {
+				names = append(names, match[2])
+			}
+			continue
+		}
+		names = append(names, match[4])
+	}
+	return names
+}
+
+func envKeyValueFormat() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "env-key-value-format",
+		Summary: "Use ENV key=value rather than the legacy ENV key value format",
+		Details: "The legacy ENV key value format sets a single variable to the remainder of the line, and is ambiguous when the value contains spaces. " +
+			"Use ENV key=value, which allows setting multiple variables in one instruction.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Env},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/build-checks/legacy-key-value-format/"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				env := instructionOf(node, validationContext).Env()
+				if env == nil {
+					return model.Skipped
+				}
+				for _, pair := range env.Env {
+					if pair.NoDelim {
+						return model.Recommendation
+					}
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func envMixedFormat() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:             "env-mixed-format",
+		Summary:          "Avoid mixing ENV key value and ENV key=value formats",
+		Details:          "Using both formats in the same Dockerfile is inconsistent and easy to misread. Use ENV key=value throughout.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Env},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#env"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				legacy := make([]validations.ValidationContext, 0)
+				delimited := false
+				for _, nodeContext := range *mcr.ContextCache {
+					env := instructionOf(&nodeContext.Node, nodeContext.Context).Env()
+					if env == nil {
+						continue
+					}
+					for _, pair := range env.Env {
+						if pair.NoDelim {
+							validationContext := nodeContext.Context
+							validationContext.CausedFailure = true
+							legacy = append(legacy, validationContext)
+							break
+						}
+						delimited = true
+					}
+				}
+
+				if len(legacy) == 0 || !delimited {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  mcr.GetSummary(),
+					Contexts: legacy,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func envUnsetInRun() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "env-unset-in-run",
+		Summary: "Avoid unsetting or exporting variables in RUN which were set by ENV",
+		Details: "Each RUN instruction runs in a separate shell, so unset or export only affects that instruction; the value set by ENV remains in the image. " +
+			"If a variable is only needed while building, set it within the RUN instruction (e.g. RUN export NAME=value && ... && unset NAME) or use ARG.",
+		Priority: model.MediumPriority,
+		Commands: []commands.DockerCommand{commands.Env, commands.Run},
+		URL:      model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#env"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				envKeys := make([]string, 0)
+				leaked := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				unevaluated := unevaluatedRuns{}
+				for _, nodeContext := range *mcr.ContextCache {
+					if env := instructionOf(&nodeContext.Node, nodeContext.Context).Env(); env != nil {
+						for _, pair := range env.Env {
+							envKeys = append(envKeys, pair.Key)
+						}
+						continue
+					}
+
+					posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+					if err != nil {
+						unevaluated.add(nodeContext.Context, err)
+						continue
+					}
+					for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+						if command.Name != "unset" && command.Name != "export" {
+							continue
+						}
+						for i, arg := range command.Args {
+							name, _, _ := strings.Cut(arg, "=")
+							if strings.HasPrefix(arg, "-") || !model.StringSliceContains(&envKeys, name) {
+								continue
+							}
+							leaked = append(leaked, name)
+							validationContext := nodeContext.Context
+							validationContext.Line = arg
+							validationContext.CausedFailure = true
+							if location := command.ArgLocations[i]; location.Start.Line > 0 {
+								validationContext.Locations = []docker.Location{location}
+							}
+							validationContexts = append(validationContexts, validationContext)
+						}
+					}
+				}
+
+				if len(leaked) == 0 {
+					return unevaluated.result(&validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					})
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Variables: %s", mcr.GetSummary(), strings.Join(leaked, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// stageEnvironment is the environment of a build stage
+type stageEnvironment struct {
+	// defined are the variables declared by ARG or ENV in the stage, or in the stages it's built from
+	defined []string
+	// image describes the base image, or is nil if its environment is unknown (e.g. an image missing from the catalog)
+	image *catalog.ImageFacts
+}
+
+// newStageEnvironment creates the environment of a stage built from inherited, a named stage, or from the image
+// baseName when inherited is nil
+func newStageEnvironment(inherited *stageEnvironment, baseName string, settings Settings) *stageEnvironment {
+	if inherited != nil {
+		return &stageEnvironment{defined: append([]string{}, inherited.defined...), image: inherited.image}
+	}
+	environment := &stageEnvironment{}
+	if facts, found := imageCatalog(settings).Lookup(baseName); found {
+		environment.image = &facts
+	}
+	return environment
+}
+
+// isDefined determines whether the variable is declared in the stage, or set by the base image or the runtime
+func (e *stageEnvironment) isDefined(name string) bool {
+	return model.StringSliceContains(&e.defined, name) || model.StringSliceContains(&inheritedEnvironment, name) ||
+		(e.image != nil && e.image.DefinesEnv(name))
+}
+
+func envUndefinedVariable() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "env-undefined-variable",
+		Summary: "ENV values should only reference variables defined by a previous ARG or ENV",
+		Details: "Variables which are not defined in the build stage expand to an empty string. ARGs declared before the first FROM must be redeclared " +
+			"within the stage, and ENV values within a single instruction see the values from before that instruction. " +
+			"Use ${NAME:-default} when a variable is intentionally optional. Variables set by the base image are taken from the image catalog, " +
+			"which may be extended via settings.image_catalog. Other base images may set any variable, so only ARGs which weren't redeclared " +
+			"and keys of the same instruction are reported.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Env, commands.Arg, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#environment-replacement"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// variables defined by each named stage, inherited by stages built FROM it
+				stages := make(map[string]*stageEnvironment)
+				// ARGs declared before the first FROM, which must be redeclared within a stage
+				preamble := &stageEnvironment{}
+				current := preamble
+				undefined := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					switch {
+					case parsed.From() != nil:
+						stage := parsed.From()
+						current = newStageEnvironment(stages[strings.ToLower(stage.BaseName)], stage.BaseName, settingsOf(nodeContext.Context))
+						if stage.Name != "" {
+							stages[strings.ToLower(stage.Name)] = current
+						}
+					case parsed.Arg() != nil:
+						for _, arg := range parsed.Arg().Args {
+							current.defined = append(current.defined, arg.Key)
+						}
+					case parsed.Env() != nil:
+						escapeToken := nodeContext.Context.Directives.EscapeToken()
+						keys := make([]string, 0, len(parsed.Env().Env))
+						for _, pair := range parsed.Env().Env {
+							keys = append(keys, pair.Key)
+						}
+						for _, pair := range parsed.Env().Env {
+							for _, name := range referencedVariables(pair.Value, escapeToken) {
+								if current.isDefined(name) {
+									continue
+								}
+								// the variables of base images missing from the catalog are unknown
+								if current.image == nil && !model.StringSliceContains(&preamble.defined, name) && !model.StringSliceContains(&keys, name) {
+									continue
+								}
+								undefined = append(undefined, name)
+								validationContext := nodeContext.Context
+								validationContext.Line = name
+								validationContext.HasRecommendations = true
+								validationContexts = append(validationContexts, validationContext)
+							}
+						}
+						// values within one ENV instruction are expanded before any of its variables are set
+						current.defined = append(current.defined, keys...)
+					}
+				}
+
+				if len(undefined) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  fmt.Sprintf("%s Undefined: %s", mcr.GetSummary(), strings.Join(undefined, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(envKeyValueFormat())
+	AddRule(envMixedFormat())
+	AddRule(envUnsetInRun())
+	AddRule(envUndefinedVariable())
+}
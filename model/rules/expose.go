@@ -0,0 +1,286 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/docker/types"
+	"github.com/jimschubert/docked/model/validations"
+	log "github.com/sirupsen/logrus"
+)
+
+// exposedPort is an argument of EXPOSE, with any variables resolved from the ARG and ENV instructions before it
+type exposedPort struct {
+	types.Expose
+	// Text is the argument as written, e.g. ${PORT}/tcp
+	Text string
+	// Line is the start line of the EXPOSE instruction
+	Line int
+	// Stage is the build stage of the EXPOSE instruction
+	Stage *docker.Stage
+	// Location of the argument, or a zero Location if it can't be found
+	Location docker.Location
+	// Err is the reason the argument isn't a valid port or range of ports
+	Err error
+	// Unresolved lists the variables referenced by the argument which have no value
+	Unresolved []string
+}
+
+// isResolved determines whether the port is known: valid, and without variables lacking a value
+func (p exposedPort) isResolved() bool {
+	return p.Err == nil && len(p.Unresolved) == 0
+}
+
+// isInherited determines whether the port is exposed by stage, or by a stage which stage is built from
+func (p exposedPort) isInherited(stage *docker.Stage) bool {
+	for current := stage; current != nil; current = current.Base {
+		if current == p.Stage {
+			return true
+		}
+	}
+	return false
+}
+
+// exposedPortsOf parses the arguments of each EXPOSE instruction within contextCache, in order, resolving variables from
+// the ARG and ENV instructions before them
+func exposedPortsOf(contextCache []validations.NodeValidationContext) []exposedPort {
+	ports := make([]exposedPort, 0)
+	for _, nodeContext := range contextCache {
+		if commands.Of(nodeContext.Node.Value) != commands.Expose {
+			continue
+		}
+		cursor := docker.Position{}
+		for next := nodeContext.Node.Next; next != nil; next = next.Next {
+			port := exposedPort{Text: next.Value, Line: nodeContext.Node.StartLine, Stage: nodeContext.Context.Stage}
+			if location, ok := nodeContext.Context.Source.Find(next.Value, cursor); ok {
+				port.Location = location
+				cursor = location.End
+			}
+			expanded, unresolved, err := nodeContext.Context.Variables.Expand(next.Value, nodeContext.Context.Directives.EscapeToken())
+			if err != nil {
+				port.Err = err
+			} else if err = port.Expose.Parse(expanded); err != nil {
+				// a variable without a value is unresolved rather than invalid, unless its default (${PORT:-8080}) gives a port
+				port.Unresolved = unresolved
+				if len(unresolved) == 0 {
+					port.Err = err
+				}
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// exposeCheck finds problems with the ports exposed by a Dockerfile, by the line of their EXPOSE instruction
+type exposeCheck func(ports []exposedPort, settings Settings) map[int][]scriptFinding
+
+// evaluate applies the check to the ports of the Dockerfile, resulting in result for any findings
+func (check exposeCheck) evaluate(result model.Valid) func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	return func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+		if mcr == nil || mcr.ContextCache == nil {
+			return validations.NewValidationResultSkipped(mcr.GetSummary())
+		}
+		findings := check(exposedPortsOf(*mcr.ContextCache), ruleSettings(mcr))
+		return runFindings(mcr, result, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+			return findings[nodeContext.Node.StartLine]
+		})
+	}
+}
+
+// describe formats the port as written, along with the value of any variables (e.g. $PORT=8080)
+func (p exposedPort) describe() string {
+	if p.Err == nil && p.Original != p.Text {
+		return fmt.Sprintf("%s=%s", p.Text, p.Original)
+	}
+	return p.Text
+}
+
+// policyPorts parses the ports of an ExposeSettings list, ignoring invalid entries
+func policyPorts(setting string, entries []string) types.ExposeList {
+	ports := types.ExposeList{}
+	for _, entry := range entries {
+		port := types.Expose{}
+		if err := port.Parse(entry); err != nil {
+			log.Warnf("Ignoring invalid port %s in settings.expose.%s: %s", entry, setting, err)
+			continue
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// appliesTo determines whether a port of a policy applies to the protocol of an exposed port. Policies without a
+// protocol apply to any protocol, and exposed ports without a protocol are tcp.
+func appliesTo(policy types.Expose, exposed types.Expose) bool {
+	if policy.Protocol == "" {
+		return true
+	}
+	protocol := exposed.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return policy.Protocol == protocol
+}
+
+func invalidExpose() validations.Rule {
+	check := exposeCheck(func(ports []exposedPort, _ Settings) map[int][]scriptFinding {
+		findings := make(map[int][]scriptFinding)
+		for _, port := range ports {
+			if port.Err != nil {
+				findings[port.Line] = append(findings[port.Line], scriptFinding{Location: port.Location, Text: fmt.Sprintf("%s (%s)", port.Text, port.Err)})
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "invalid-expose",
+		Summary: "EXPOSE must document valid ports or port ranges",
+		Details: "EXPOSE accepts a port (80) or ascending range of ports (8000-8099) from 1 to 65535, optionally followed by /tcp, /udp, or /sctp. " +
+			"Invalid ports, including those of variables, fail the build.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Expose},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#expose"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Failure),
+		},
+	}
+	return &r
+}
+
+func duplicateExpose() validations.Rule {
+	check := exposeCheck(func(ports []exposedPort, _ Settings) map[int][]scriptFinding {
+		findings := make(map[int][]scriptFinding)
+		for i, port := range ports {
+			if !port.isResolved() {
+				continue
+			}
+			// earlier ports of the stage, or of the stages it's built from
+			for _, other := range ports[:i] {
+				if other.isResolved() && other.isInherited(port.Stage) && port.Overlaps(other.Expose) {
+					findings[port.Line] = append(findings[port.Line], scriptFinding{
+						Location: port.Location,
+						Text:     fmt.Sprintf("%s (overlaps %s on line %d)", port.describe(), other.describe(), other.Line),
+					})
+					break
+				}
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "duplicate-expose",
+		Summary: "Avoid exposing the same port more than once",
+		Details: "Ports exposed more than once, or within an overlapping range of the same protocol, are redundant and may hide a mistake " +
+			"such as a port intended for another protocol. Ports of a stage built FROM an earlier stage include those exposed by that stage.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Expose},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#expose"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func exposeUndefinedVariable() validations.Rule {
+	check := exposeCheck(func(ports []exposedPort, _ Settings) map[int][]scriptFinding {
+		findings := make(map[int][]scriptFinding)
+		for _, port := range ports {
+			if port.Err == nil && len(port.Unresolved) > 0 {
+				findings[port.Line] = append(findings[port.Line], scriptFinding{
+					Location: port.Location,
+					Text:     fmt.Sprintf("%s (%s)", port.Text, strings.Join(port.Unresolved, ", ")),
+				})
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "expose-undefined-variable",
+		Summary: "EXPOSE variables should have a default value from ARG or ENV",
+		Details: "A port which references a variable without a value (EXPOSE $PORT) documents no port unless the build argument is provided, " +
+			"and can't be checked against the port policy. Declare a default in the stage, e.g. ARG PORT=8080. ARGs declared before the first FROM " +
+			"must be redeclared within the stage.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Expose},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#understand-how-arg-and-from-interact"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func exposeRequiredPorts() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "expose-required-ports",
+		Summary: "The final image should expose the ports required by your organization",
+		Details: "Configure settings.expose.required to list ports or ranges of which every image must expose at least one port, " +
+			"such as a service or metrics port. Ports of an earlier stage are included when the final stage is built FROM it.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Expose, commands.From},
+		AppliesToBuilder: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				required := policyPorts("required", ruleSettings(mcr).Expose.Required)
+				if mcr == nil || mcr.ContextCache == nil || len(required) == 0 {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+				// ports of the final stage, or of the stages it's built from
+				var final *validations.NodeValidationContext
+				for i, nodeContext := range *mcr.ContextCache {
+					if commands.Of(nodeContext.Node.Value) == commands.From && !nodeContext.Context.IsBuilderContext {
+						final = &(*mcr.ContextCache)[i]
+					}
+				}
+				if final == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+				ports := exposedPortsOf(*mcr.ContextCache)
+
+				missing := make([]string, 0)
+				for _, policy := range required {
+					exposed := false
+					for _, port := range ports {
+						exposed = exposed || (port.isResolved() && port.isInherited(final.Context.Stage) &&
+							appliesTo(policy, port.Expose) && policy.PortRange.Intersects(port.PortRange))
+					}
+					if !exposed {
+						missing = append(missing, policy.String())
+					}
+				}
+				if len(missing) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+
+				// a missing port is reported at the FROM of the final stage
+				validationContext := final.Context
+				validationContext.CausedFailure = true
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Found: missing %s", mcr.GetSummary(), strings.Join(missing, ", ")),
+					Contexts: []validations.ValidationContext{validationContext},
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(invalidExpose())
+	AddRule(duplicateExpose())
+	AddRule(exposeUndefinedVariable())
+	AddRule(exposeRequiredPorts())
+}
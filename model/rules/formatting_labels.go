@@ -7,9 +7,7 @@ import (
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/validations"
-	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
-	log "github.com/sirupsen/logrus"
 )
 
 func formattingLabels() validations.Rule {
@@ -19,17 +17,17 @@ func formattingLabels() validations.Rule {
 		Details: "Label keys should begin and end with a lower-case letter and should only contain lower-case " +
 			"alphanumeric characters, the period character (.), and the hyphen character (-). " +
 			"Consecutive periods or hyphens are not allowed.",
-		Priority: model.HighPriority,
-		Commands: []commands.DockerCommand{commands.Label},
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Label},
+		AppliesToOnbuild: true,
 		Evaluator: validations.MultiContextPerNodeEvaluator{
 			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
-				which, err := instructions.ParseCommand(node)
-				if err != nil {
-					log.Warnf("unable to parse label: %s", node.Value)
+				parsed := instructionOf(node, validationContext)
+				if parsed.Err != nil {
 					return model.Skipped
 				}
 
-				if labelCommand, ok := which.(*instructions.LabelCommand); ok && labelCommand != nil {
+				if labelCommand := parsed.Label(); labelCommand != nil {
 					illegalRune := func(c rune) bool {
 						if unicode.IsLetter(c) {
 							return !unicode.IsLower(c)
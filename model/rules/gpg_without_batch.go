@@ -5,19 +5,18 @@ import (
 
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
-	"github.com/jimschubert/docked/model/shell"
 	"github.com/jimschubert/docked/model/validations"
-	log "github.com/sirupsen/logrus"
 )
 
 func gpgWithoutBatch() validations.Rule {
 	r := validations.MultiContextRule{
-		Name:     "gpg-without-batch",
-		Summary:  "GPG call without --batch (or --no-tty) may error.",
-		Details:  "Running GPG without --batch (or --no-tty) may cause GPG to fail opening /dev/tty, resulting in docker build failures.",
-		Priority: model.MediumPriority,
-		Commands: []commands.DockerCommand{commands.Run},
-		URL:      model.StringPtr("https://bugs.debian.org/cgi-bin/bugreport.cgi?bug=913614"),
+		Name:             "gpg-without-batch",
+		Summary:          "GPG call without --batch (or --no-tty) may error.",
+		Details:          "Running GPG without --batch (or --no-tty) may cause GPG to fail opening /dev/tty, resulting in docker build failures.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://bugs.debian.org/cgi-bin/bugreport.cgi?bug=913614"),
 
 		Evaluator: validations.MultiContextFullEvaluator{
 			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
@@ -28,12 +27,12 @@ func gpgWithoutBatch() validations.Rule {
 					}
 				}
 				result := model.Success
+				unevaluated := unevaluatedRuns{}
 
 				for _, nodeContext := range *mcr.ContextCache {
-					posixCommands, err := shell.NewPosixCommandFromNode(&nodeContext.Node)
+					posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
 					if err != nil {
-						log.Warnf("Unable to parse RUN command, skipping validation: %#v", nodeContext.Node.Location())
-						result = model.Skipped
+						unevaluated.add(nodeContext.Context, err)
 					} else {
 						for _, command := range posixCommands {
 							if (command.Name == "gpg" || command.Name == `\gpg`) && command.Args != nil {
@@ -53,11 +52,11 @@ func gpgWithoutBatch() validations.Rule {
 					}
 				}
 
-				return &validations.ValidationResult{
+				return unevaluated.result(&validations.ValidationResult{
 					Result:   result,
 					Details:  mcr.GetSummary(),
 					Contexts: *mcr.GetContexts(),
-				}
+				})
 			},
 		},
 	}
@@ -0,0 +1,344 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+const (
+	// defaultHealthcheckInterval is the interval docker applies when --interval is omitted
+	defaultHealthcheckInterval = 30 * time.Second
+	// defaultHealthcheckTimeout is the timeout docker applies when --timeout is omitted
+	defaultHealthcheckTimeout = 30 * time.Second
+	// minimumHealthcheckInterval is the shortest interval considered reasonable, as each check runs a new process
+	minimumHealthcheckInterval = time.Second
+)
+
+// healthcheckTools are the HTTP clients commonly called by health checks
+var healthcheckTools = []string{"curl", "wget"}
+
+// healthcheckCommands returns the commands run by a health check, parsing CMD-SHELL with the active shell
+func healthcheckCommands(healthcheck *instructions.HealthCheckCommand, activeShell []string) []shell.PosixCommand {
+	if healthcheck == nil || healthcheck.Health == nil || len(healthcheck.Health.Test) < 2 {
+		return nil
+	}
+	test := healthcheck.Health.Test
+	var posixCommands []shell.PosixCommand
+	switch test[0] {
+	case "CMD":
+		posixCommands, _ = shell.NewPosixCommandFromExec(test[1:])
+	case "CMD-SHELL":
+		posixCommands, _ = shell.NewPosixCommandWithShell(test[1], activeShell)
+	}
+	return posixCommands
+}
+
+func healthcheckForServices() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "healthcheck-for-services",
+		Summary: "Define a HEALTHCHECK for images which EXPOSE a port",
+		Details: "Images exposing a port are typically long-running services. A HEALTHCHECK allows the container runtime to detect " +
+			"a service which is running but unable to respond. Use HEALTHCHECK NONE to explicitly disable a health check inherited from the base image.",
+		Priority: model.MediumPriority,
+		Commands: []commands.DockerCommand{commands.Expose, commands.Healthcheck},
+		URL:      model.StringPtr("https://docs.docker.com/reference/dockerfile/#healthcheck"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				hasHealthcheck := false
+				exposeContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					if commands.Of(nodeContext.Node.Value) == commands.Healthcheck {
+						hasHealthcheck = true
+						continue
+					}
+					validationContext := nodeContext.Context
+					validationContext.HasRecommendations = true
+					exposeContexts = append(exposeContexts, validationContext)
+				}
+
+				if hasHealthcheck || len(exposeContexts) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  mcr.GetSummary(),
+					Contexts: exposeContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func singleHealthcheck() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:     "single-healthcheck",
+		Summary:  "Only a single HEALTHCHECK instruction is supported",
+		Details:  "Only the last HEALTHCHECK takes effect. Earlier health checks are ignored, which may indicate a programming error.",
+		Priority: model.MediumPriority,
+		Commands: []commands.DockerCommand{commands.Healthcheck},
+		URL:      model.StringPtr("https://docs.docker.com/reference/dockerfile/#healthcheck"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					validationContexts = append(validationContexts, nodeContext.Context)
+				}
+
+				if len(validationContexts) < 2 {
+					return &validations.ValidationResult{
+						Result:   model.Success,
+						Details:  mcr.GetSummary(),
+						Contexts: validationContexts,
+					}
+				}
+				// all but the last are overridden
+				for i := 0; i < len(validationContexts)-1; i++ {
+					validationContexts[i].CausedFailure = true
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  mcr.GetSummary(),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// healthcheckOptionProblems describes the invalid or questionable options of the HEALTHCHECK at nodeContext,
+// along with the locations of the offending flags
+func healthcheckOptionProblems(nodeContext validations.NodeValidationContext) ([]string, []docker.Location) {
+	problems := make([]string, 0)
+	locations := make([]docker.Location, 0)
+	flags := make(map[string]string)
+	for _, flag := range nodeContext.Node.Flags {
+		name, value, _ := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		flags[name] = value
+	}
+	report := func(flag string, problem string) {
+		problems = append(problems, problem)
+		if value, ok := flags[flag]; ok {
+			if location, found := nodeContext.Context.Source.Find(fmt.Sprintf("--%s=%s", flag, value), docker.Position{}); found {
+				locations = append(locations, location)
+			}
+		}
+	}
+
+	parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+	if parsed.Err != nil {
+		problems = append(problems, parsed.Err.Error())
+		return problems, locations
+	}
+	healthcheck := parsed.Healthcheck()
+	if healthcheck == nil || healthcheck.Health == nil || (len(healthcheck.Health.Test) > 0 && healthcheck.Health.Test[0] == "NONE") {
+		return problems, locations
+	}
+
+	interval, timeout := healthcheck.Health.Interval, healthcheck.Health.Timeout
+	if interval > 0 && interval < minimumHealthcheckInterval {
+		report("interval", fmt.Sprintf("--interval=%s is less than %s", interval, minimumHealthcheckInterval))
+	}
+	if interval == 0 {
+		interval = defaultHealthcheckInterval
+	}
+	if timeout == 0 {
+		timeout = defaultHealthcheckTimeout
+	}
+	if timeout > interval {
+		report("timeout", fmt.Sprintf("--timeout=%s exceeds the interval of %s", timeout, interval))
+	}
+	if value, ok := flags["retries"]; ok && healthcheck.Health.Retries == 0 {
+		report("retries", fmt.Sprintf("--retries=%s applies the default of 3 retries", value))
+	}
+	return problems, locations
+}
+
+func healthcheckOptions() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "healthcheck-options",
+		Summary: "HEALTHCHECK options should be valid durations and counts",
+		Details: "--interval, --timeout, and --start-period must be durations such as 30s, and --retries a positive count. " +
+			"An interval under 1s runs a process continuously, a timeout longer than the interval overlaps checks, and --retries=0 falls back to the default.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Healthcheck},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#healthcheck"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				found := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					problems, locations := healthcheckOptionProblems(nodeContext)
+					if len(problems) == 0 {
+						continue
+					}
+					found = append(found, problems...)
+					validationContext := nodeContext.Context
+					validationContext.CausedFailure = true
+					if len(locations) > 0 {
+						validationContext.Locations = locations
+					}
+					validationContexts = append(validationContexts, validationContext)
+				}
+
+				if len(found) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Invalid: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// stageTools tracks which of healthcheckTools are available within a build stage
+type stageTools struct {
+	// known is false when the base image is missing from the catalog, or the catalog doesn't describe its tools
+	known bool
+	// available are the tools included in the base image or installed by the stage
+	available map[string]bool
+}
+
+// baseImageTools returns the healthcheckTools included in image, as described by the image catalog
+func baseImageTools(image string, settings Settings) stageTools {
+	tools := stageTools{available: make(map[string]bool)}
+	facts, found := imageCatalog(settings).Lookup(image)
+	if !found {
+		return tools
+	}
+	for _, tool := range healthcheckTools {
+		tools.available[tool], tools.known = facts.HasTool(tool)
+	}
+	return tools
+}
+
+func healthcheckMissingTool() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "healthcheck-missing-tool",
+		Summary: "HEALTHCHECK calls curl or wget which the stage doesn't install",
+		Details: "Slim and distroless base images often lack curl and wget, causing the health check to fail and the container to be reported unhealthy. " +
+			"Install the tool in the stage, or use a health check built into the application. The tools of base images are described by the image catalog, " +
+			"which may be extended via settings.image_catalog; stages built from other images aren't evaluated.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Healthcheck, commands.Run, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#healthcheck"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// builder stages are tracked only so a stage built FROM a named stage inherits its tools
+				stages := make(map[string]stageTools)
+				var stageName string
+				current := stageTools{available: make(map[string]bool)}
+				missing := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				unevaluated := unevaluatedRuns{}
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					if stage := parsed.From(); stage != nil {
+						if inherited, ok := stages[strings.ToLower(stage.BaseName)]; ok {
+							current = stageTools{known: inherited.known, available: make(map[string]bool)}
+							for tool, available := range inherited.available {
+								current.available[tool] = available
+							}
+						} else {
+							current = baseImageTools(stage.BaseName, settingsOf(nodeContext.Context))
+						}
+						stageName = stage.Name
+					} else if parsed.Run() != nil {
+						posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+						if err != nil {
+							unevaluated.add(nodeContext.Context, err)
+						}
+						for _, command := range posixCommands {
+							if install, ok := findInstallCommand(command, installIndicators()); ok {
+								for _, pkg := range install.Packages {
+									name, _, _ := strings.Cut(pkg, "=")
+									if model.StringSliceContains(&healthcheckTools, name) {
+										current.available[name] = true
+									}
+								}
+							}
+						}
+					} else if healthcheck := parsed.Healthcheck(); healthcheck != nil && !nodeContext.Context.IsBuilderContext {
+						cursor := docker.Position{}
+						for _, command := range healthcheckCommands(healthcheck, nodeContext.Context.Shell) {
+							tool := path.Base(command.Name)
+							if !model.StringSliceContains(&healthcheckTools, tool) || !current.known || current.available[tool] {
+								continue
+							}
+							missing = append(missing, tool)
+							validationContext := nodeContext.Context
+							validationContext.HasRecommendations = true
+							if location, found := nodeContext.Context.Source.Find(command.Name, cursor); found {
+								cursor = location.End
+								validationContext.Locations = []docker.Location{location}
+							}
+							validationContexts = append(validationContexts, validationContext)
+						}
+					}
+					if stageName != "" {
+						stages[stageName] = current
+					}
+				}
+
+				if len(missing) == 0 {
+					return unevaluated.result(&validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					})
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  fmt.Sprintf("%s Missing: %s", mcr.GetSummary(), strings.Join(missing, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(healthcheckForServices())
+	AddRule(singleHealthcheck())
+	AddRule(healthcheckOptions())
+	AddRule(healthcheckMissingTool())
+}
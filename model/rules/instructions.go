@@ -0,0 +1,39 @@
+package rules
+
+import (
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// instructionOf returns the typed instruction parsed by the analysis engine,
+// parsing the node only when a rule is evaluated outside the engine.
+func instructionOf(node *parser.Node, validationContext validations.ValidationContext) *docker.ParsedInstruction {
+	if validationContext.Instruction != nil {
+		return validationContext.Instruction
+	}
+	return docker.ParseInstruction(node)
+}
+
+// stagesOf groups the cached contexts of a rule by build stage, in order. Contexts before the first FROM form the first group.
+// Stages without any of the rule's commands have no group; when the rule includes commands.From, each stage's group starts with its FROM.
+func stagesOf(contextCache []validations.NodeValidationContext) [][]validations.NodeValidationContext {
+	stages := make([][]validations.NodeValidationContext, 0)
+	current := make([]validations.NodeValidationContext, 0)
+	var currentStage *docker.Stage
+	for _, nodeContext := range contextCache {
+		// contexts created outside the analysis engine have no Stage, so are split at each FROM
+		startsStage := nodeContext.Context.Stage != currentStage ||
+			(nodeContext.Context.Stage == nil && instructionOf(&nodeContext.Node, nodeContext.Context).From() != nil)
+		if startsStage && len(current) > 0 {
+			stages = append(stages, current)
+			current = make([]validations.NodeValidationContext, 0)
+		}
+		currentStage = nodeContext.Context.Stage
+		current = append(current, nodeContext)
+	}
+	if len(current) > 0 {
+		stages = append(stages, current)
+	}
+	return stages
+}
@@ -0,0 +1,394 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	lexer "github.com/moby/buildkit/frontend/dockerfile/shell"
+	log "github.com/sirupsen/logrus"
+)
+
+// ociNamespace is the namespace of the pre-defined annotation keys of the OCI image spec
+const ociNamespace = "org.opencontainers.image."
+
+// labelFormat describes the expected format of a label value
+type labelFormat struct {
+	// description completes the sentence "The value must be ..."
+	description string
+	isValid     func(value string) bool
+}
+
+// ociLabels are the pre-defined annotation keys of the OCI image spec and the format of their values, if any.
+// See https://github.com/opencontainers/image-spec/blob/main/annotations.md#pre-defined-annotation-keys
+var ociLabels = map[string]*labelFormat{
+	ociNamespace + "created":       {description: "an RFC 3339 date-time", isValid: isRFC3339},
+	ociNamespace + "authors":       nil,
+	ociNamespace + "url":           {description: "a URL", isValid: isURL},
+	ociNamespace + "documentation": {description: "a URL", isValid: isURL},
+	ociNamespace + "source":        {description: "a URL", isValid: isURL},
+	ociNamespace + "version":       nil,
+	ociNamespace + "revision":      nil,
+	ociNamespace + "vendor":        nil,
+	ociNamespace + "licenses":      {description: "an SPDX license expression", isValid: isSPDXExpression},
+	ociNamespace + "ref.name":      nil,
+	ociNamespace + "title":         nil,
+	ociNamespace + "description":   nil,
+	ociNamespace + "base.digest":   nil,
+	ociNamespace + "base.name":     nil,
+}
+
+// labelEntry is a label defined by a LABEL instruction, along with the locations of its key and value.
+// Quotes are removed from values, other than those set from variables. Locations are zero when the text can't be found,
+// such as values with escaped quotes.
+type labelEntry struct {
+	Key           string
+	Value         string
+	KeyLocation   docker.Location
+	ValueLocation docker.Location
+}
+
+// labelsOf finds the labels defined by a LABEL instruction, or nil for other instructions and those which can't be parsed
+func labelsOf(nodeContext validations.NodeValidationContext) []labelEntry {
+	parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+	labelCommand := parsed.Label()
+	if parsed.Err != nil || labelCommand == nil {
+		return nil
+	}
+
+	source := nodeContext.Context.Source
+	cursor := docker.Position{}
+	if source != nil && len(source.Lines) > 0 {
+		// skip the LABEL keyword
+		indent := len(source.Lines[0]) - len(strings.TrimLeft(source.Lines[0], " \t"))
+		cursor = docker.Position{Line: source.StartLine, Character: indent + len(nodeContext.Node.Value)}
+	}
+	find := func(text string) docker.Location {
+		if location, ok := source.FindText(text, cursor); ok {
+			cursor = location.End
+			return location
+		}
+		return docker.Location{}
+	}
+
+	lex := lexer.NewLex(nodeContext.Context.Directives.EscapeToken())
+	labels := make([]labelEntry, 0, len(labelCommand.Labels))
+	for _, kvp := range labelCommand.Labels {
+		entry := labelEntry{Key: kvp.Key, Value: kvp.Value}
+		if !isVariableValue(kvp.Value) {
+			if unquoted, _, err := lex.ProcessWord(kvp.Value, lexer.EnvsFromSlice(nil)); err == nil {
+				entry.Value = unquoted
+			}
+		}
+		entry.KeyLocation = find(kvp.Key)
+		entry.ValueLocation = find(entry.Value)
+		labels = append(labels, entry)
+	}
+	return labels
+}
+
+// isVariableValue determines whether a label value is set from a build argument or environment variable, e.g. $BUILD_DATE,
+// which can only be validated at build time
+func isVariableValue(value string) bool {
+	return strings.Contains(value, "$")
+}
+
+// isRFC3339 determines whether value is an RFC 3339 date-time, e.g. 2024-01-02T15:04:05Z
+func isRFC3339(value string) bool {
+	_, err := time.Parse(time.RFC3339, value)
+	return err == nil
+}
+
+// isURL determines whether value is an absolute URL
+func isURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
+
+// spdxIdentifier matches a license or exception identifier, optionally followed by + (or later), or a LicenseRef
+var spdxIdentifier = regexp.MustCompile(`^(DocumentRef-[A-Za-z0-9.\-]+:)?(LicenseRef-)?[A-Za-z0-9][A-Za-z0-9.\-]*\+?$`)
+
+// spdxExpression is a recursive descent parser of SPDX license expressions
+type spdxExpression struct {
+	tokens []string
+	pos    int
+}
+
+// isSPDXExpression determines whether value is a valid SPDX license expression, e.g. MIT, Apache-2.0 OR MIT, or
+// GPL-2.0-or-later WITH Classpath-exception-2.0. See https://spdx.github.io/spdx-spec/v2.3/SPDX-license-expressions/
+func isSPDXExpression(value string) bool {
+	expression := spdxExpression{tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(value))}
+	return expression.compound() && expression.pos == len(expression.tokens)
+}
+
+func (e *spdxExpression) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos]
+	}
+	return ""
+}
+
+// compound parses: simple ((AND | OR) simple)*
+func (e *spdxExpression) compound() bool {
+	if !e.simple() {
+		return false
+	}
+	for e.peek() == "AND" || e.peek() == "OR" {
+		e.pos++
+		if !e.simple() {
+			return false
+		}
+	}
+	return true
+}
+
+// simple parses: ( compound ) | identifier [WITH identifier]
+func (e *spdxExpression) simple() bool {
+	if e.peek() == "(" {
+		e.pos++
+		if !e.compound() || e.peek() != ")" {
+			return false
+		}
+		e.pos++
+		return true
+	}
+	if !e.identifier() {
+		return false
+	}
+	if e.peek() == "WITH" {
+		e.pos++
+		return e.identifier()
+	}
+	return true
+}
+
+func (e *spdxExpression) identifier() bool {
+	token := e.peek()
+	if token == "AND" || token == "OR" || token == "WITH" || !spdxIdentifier.MatchString(token) {
+		return false
+	}
+	e.pos++
+	return true
+}
+
+// labelCheck finds problems with the labels of a LABEL instruction
+type labelCheck func(labels []labelEntry) []scriptFinding
+
+// evaluate applies the check to the labels of each LABEL instruction, resulting in result for any findings
+func (check labelCheck) evaluate(result model.Valid) func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	return func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+		return runFindings(mcr, result, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+			return check(labelsOf(nodeContext))
+		})
+	}
+}
+
+func openContainersLabelValues() validations.Rule {
+	check := labelCheck(func(labels []labelEntry) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		for _, label := range labels {
+			if !strings.HasPrefix(label.Key, ociNamespace) {
+				continue
+			}
+			format, defined := ociLabels[label.Key]
+			switch {
+			case !defined:
+				findings = append(findings, scriptFinding{Location: label.KeyLocation, Text: fmt.Sprintf("%s (not a pre-defined key)", label.Key)})
+			case format != nil && !isVariableValue(label.Value) && !format.isValid(label.Value):
+				findings = append(findings, scriptFinding{
+					Location: label.ValueLocation,
+					Text:     fmt.Sprintf("%s=%q (must be %s)", label.Key, label.Value, format.description),
+				})
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "oci-label-values",
+		Summary: "Open Containers Initiative annotations should have valid keys and values",
+		Details: "Tools which read OCI annotations expect the pre-defined keys of the org.opencontainers.image namespace, " +
+			"with created as an RFC 3339 date-time (2024-01-02T15:04:05Z), licenses as an SPDX license expression (Apache-2.0 OR MIT), " +
+			"and url, documentation, and source as URLs. Values set from variables are not evaluated.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Label},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://github.com/opencontainers/image-spec/blob/main/annotations.md#pre-defined-annotation-keys"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Failure),
+		},
+	}
+	return &r
+}
+
+func namespacedLabelKeys() validations.Rule {
+	check := labelCheck(func(labels []labelEntry) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		for _, label := range labels {
+			if !strings.Contains(label.Key, ".") {
+				findings = append(findings, scriptFinding{Location: label.KeyLocation, Text: label.Key})
+			}
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "namespaced-label-keys",
+		Summary: "Label keys should be prefixed by a namespace",
+		Details: "The period character (.) separates namespace fields. Label keys without a namespace are reserved for CLI use, " +
+			"allowing users to interactively label objects with short keys. Prefix keys with the reverse DNS notation of a domain you own, e.g. com.example.version.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Label},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/config/labels-custom-metadata/#key-format-recommendations"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func requiredLabels() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "required-labels",
+		Summary: "The final image should define the labels required by your organization",
+		Details: "Configure settings.labels.required to list label keys which every image must define, such as an owning team " +
+			"or org.opencontainers.image.source. Labels of earlier stages are included when the final stage is built FROM them.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Label, commands.From},
+		AppliesToBuilder: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				required := ruleSettings(mcr).Labels.Required
+				if mcr == nil || mcr.ContextCache == nil || len(required) == 0 {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// labels by the lower-case name of the stage defining them, inherited by stages built FROM that stage
+				stageLabels := make(map[string]map[string]bool)
+				var defined map[string]bool
+				var final validations.NodeValidationContext
+				for _, stage := range stagesOf(*mcr.ContextCache) {
+					defined = make(map[string]bool)
+					final = stage[0]
+					from := instructionOf(&stage[0].Node, stage[0].Context).From()
+					if from != nil {
+						for key := range stageLabels[strings.ToLower(from.BaseName)] {
+							defined[key] = true
+						}
+					}
+					for _, nodeContext := range stage {
+						for _, label := range labelsOf(nodeContext) {
+							defined[label.Key] = true
+						}
+					}
+					if from != nil && from.Name != "" {
+						stageLabels[strings.ToLower(from.Name)] = defined
+					}
+				}
+
+				missing := make([]string, 0)
+				for _, key := range required {
+					if !defined[key] {
+						missing = append(missing, key)
+					}
+				}
+				if len(missing) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+
+				// a missing label is reported at the FROM of the final stage
+				validationContext := final.Context
+				validationContext.CausedFailure = true
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Found: missing %s", mcr.GetSummary(), strings.Join(missing, ", ")),
+					Contexts: []validations.ValidationContext{validationContext},
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// labelPattern is a configured regular expression for the values of labels matching keyPattern
+type labelPattern struct {
+	keyPattern string
+	expression *regexp.Regexp
+}
+
+// configuredLabelPatterns compiles the patterns of LabelSettings, ordered by key pattern. Invalid patterns are ignored.
+func configuredLabelPatterns(settings Settings) []labelPattern {
+	configured := settings.Labels.Patterns
+	patterns := make([]labelPattern, 0, len(configured))
+	for keyPattern, expression := range configured {
+		compiled, err := regexp.Compile(expression)
+		if err != nil {
+			log.Warnf("Ignoring label pattern for %s with invalid expression: %s", keyPattern, err)
+			continue
+		}
+		patterns = append(patterns, labelPattern{keyPattern: keyPattern, expression: compiled})
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].keyPattern < patterns[j].keyPattern
+	})
+	return patterns
+}
+
+func labelValueFormat() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "label-value-format",
+		Summary: "Label values should match the formats required by your organization",
+		Details: "Configure settings.labels.patterns to require the values of labels to match a regular expression, by key or namespace (com.example.*). " +
+			"Anchor expressions with ^ and $ to match the entire value. Values set from variables are not evaluated.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Label},
+		AppliesToBuilder: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				patterns := configuredLabelPatterns(ruleSettings(mcr))
+				if len(patterns) == 0 {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+				check := labelCheck(func(labels []labelEntry) []scriptFinding {
+					findings := make([]scriptFinding, 0)
+					for _, label := range labels {
+						if isVariableValue(label.Value) {
+							continue
+						}
+						for _, pattern := range patterns {
+							if matched, _ := path.Match(pattern.keyPattern, label.Key); matched && !pattern.expression.MatchString(label.Value) {
+								findings = append(findings, scriptFinding{
+									Location: label.ValueLocation,
+									Text:     fmt.Sprintf("%s=%q (must match %s)", label.Key, label.Value, pattern.expression),
+								})
+								break
+							}
+						}
+					}
+					return findings
+				})
+				return check.evaluate(model.Failure)(mcr)
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(openContainersLabelValues())
+	AddRule(namespacedLabelKeys())
+	AddRule(requiredLabels())
+	AddRule(labelValueFormat())
+}
@@ -8,13 +8,14 @@ import (
 
 func maintainerDeprecated() validations.Rule {
 	r := validations.SimpleRegexRule{
-		Name:     "maintainer-deprecated",
-		Summary:  "MAINTAINER is deprecated",
-		Details:  "MAINTAINER instruction is deprecated; Use LABEL instead, which can be queried via `docker inspect`.",
-		Pattern:  `[[:graph:]]+`,
-		Priority: model.LowPriority,
-		Command:  commands.Maintainer,
-		URL:      model.StringPtr("https://docs.docker.com/engine/reference/builder/#maintainer-deprecated"),
+		Name:             "maintainer-deprecated",
+		Summary:          "MAINTAINER is deprecated",
+		Details:          "MAINTAINER instruction is deprecated; Use LABEL instead, which can be queried via `docker inspect`.",
+		Pattern:          `[[:graph:]]+`,
+		Priority:         model.LowPriority,
+		Command:          commands.Maintainer,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/engine/reference/builder/#maintainer-deprecated"),
 	}
 	return &r
 }
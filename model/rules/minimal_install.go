@@ -0,0 +1,124 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// minimalInstall describes the install option of a package manager which avoids installing optional content
+type minimalInstall struct {
+	// managers detects the package manager's install commands
+	managers model.PredicateMap
+	// isMinimal determines whether an install argument applies the option
+	isMinimal func(arg string) bool
+	// requires maps a manager to the package manager which the base image must include for the option to apply,
+	// e.g. yum supports install_weak_deps only where it's provided by dnf
+	requires map[string]string
+}
+
+// isFalseOption determines whether arg sets option (e.g. install_weak_deps=False) to a false value
+func isFalseOption(arg string, option string) bool {
+	value, ok := strings.CutPrefix(arg, option+"=")
+	if !ok {
+		return false
+	}
+	value = strings.ToLower(value)
+	return value == "false" || value == "0" || value == "no"
+}
+
+// appliesTo determines whether the option applies to installs by manager within the stage of validationContext.
+// Managers with a requirement are skipped for base images missing from the image catalog.
+func (m minimalInstall) appliesTo(manager string, validationContext validations.ValidationContext) bool {
+	required, ok := m.requires[manager]
+	if !ok {
+		return true
+	}
+	facts, found := stageImageFacts(validationContext)
+	return found && facts.HasPackageManager(required)
+}
+
+// evaluate recommends the option for each install within the RUN instruction which doesn't apply it
+func (m minimalInstall) evaluate(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+	posixCommands, err := runCommands(node, validationContext)
+	if err != nil {
+		return model.Skipped
+	}
+
+	for _, command := range posixCommands {
+		install, ok := findInstallCommand(command, m.managers)
+		if !ok || !m.appliesTo(install.Manager, validationContext) {
+			continue
+		}
+		minimal := false
+		for _, arg := range command.Args {
+			if m.isMinimal(arg) {
+				minimal = true
+				break
+			}
+		}
+		if !minimal {
+			return model.Recommendation
+		}
+	}
+	return model.Success
+}
+
+func minimalInstallRule(name string, summary string, details string, url string, install minimalInstall) validations.Rule {
+	r := validations.MultiContextRule{
+		Name:             name,
+		Summary:          summary,
+		Details:          details,
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr(url),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn:          install.evaluate,
+			Unevaluated: unevaluatedRun,
+		},
+	}
+	return &r
+}
+
+func aptNoInstallRecommends() validations.Rule {
+	return minimalInstallRule(
+		"apt-no-install-recommends",
+		"Use apt-get install --no-install-recommends",
+		"By default, apt installs recommended packages along with those requested, which increases image size and attack surface. "+
+			"Use --no-install-recommends and explicitly install any packages which are needed.",
+		"https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#apt-get",
+		minimalInstall{
+			managers: managerLookup("apt", "apt-get"),
+			isMinimal: func(arg string) bool {
+				return arg == "--no-install-recommends" || isFalseOption(strings.TrimPrefix(arg, "-o"), "APT::Install-Recommends")
+			},
+		},
+	)
+}
+
+func dnfNoWeakDeps() validations.Rule {
+	return minimalInstallRule(
+		"dnf-no-weak-deps",
+		"Use dnf install --setopt=install_weak_deps=False",
+		"By default, dnf (and yum on dnf-based distributions) installs weak dependencies along with those requested, which increases image size and attack surface. "+
+			"Use --setopt=install_weak_deps=False and explicitly install any packages which are needed. "+
+			"yum is evaluated only for base images which the image catalog describes as including dnf, since yum 3 (e.g. centos:7 and amazonlinux:2) ignores the option.",
+		"https://dnf.readthedocs.io/en/latest/conf_ref.html#install-weak-deps-label",
+		minimalInstall{
+			managers: managerLookup("yum", "dnf", "microdnf"),
+			isMinimal: func(arg string) bool {
+				return isFalseOption(strings.TrimPrefix(arg, "--setopt="), "install_weak_deps")
+			},
+			requires: map[string]string{"yum": "dnf"},
+		},
+	)
+}
+
+func init() {
+	AddRule(aptNoInstallRecommends())
+	AddRule(dnfNoWeakDeps())
+}
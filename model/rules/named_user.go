@@ -6,7 +6,6 @@ import (
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/validations"
-	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,12 +20,11 @@ func namedUser() validations.Rule {
 	}
 
 	isUserInstructionValid := func(c *validations.NodeValidationContext) bool {
-		i, err := instructions.ParseInstruction(&c.Node)
-		if err != nil {
-			log.Warnf("failed parsing user command: %s", err)
+		parsed := instructionOf(&c.Node, c.Context)
+		if parsed.Err != nil {
 			return false
 		}
-		if user, ok := i.(*instructions.UserCommand); ok && user != nil {
+		if user := parsed.User(); user != nil {
 			return isUserName(user.User)
 		}
 
@@ -34,17 +32,13 @@ func namedUser() validations.Rule {
 	}
 
 	isCopyInstructionValid := func(c *validations.NodeValidationContext) bool {
-		i, err := instructions.ParseInstruction(&c.Node)
-		if err != nil {
-			log.Warnf("failed parsing copy command: %s", err)
+		parsed := instructionOf(&c.Node, c.Context)
+		if parsed.Err != nil {
 			return false
 		}
 
-		if copyCommand, ok := i.(*instructions.CopyCommand); ok && copyCommand != nil {
-			chown := copyCommand.Chown
-			if chown != "" {
-				return isUserName(chown)
-			}
+		if chown := parsed.Flags.Chown; chown != "" {
+			return isUserName(chown)
 		}
 
 		return true
@@ -18,26 +18,18 @@ func isLatest(image string) bool {
 	return (len(imageParts) == 1 && imageParts[0] != "scratch") || imageParts[len(imageParts)-1] == "latest"
 }
 
-func processFrom(node *parser.Node, handler func(image string, builderName *string) *validations.ValidationResult) *validations.ValidationResult {
-	var image string
-	var isBuilder = false
-	var builderName *string = nil
+func processFrom(node *parser.Node, validationContext validations.ValidationContext, handler func(image string, builderName *string) *validations.ValidationResult) *validations.ValidationResult {
+	stage := instructionOf(node, validationContext).From()
+	if stage == nil {
+		return validations.NewValidationResultSkipped("Unable to parse the FROM instruction")
+	}
 
-	part := node.Next
-	for part != nil {
-		if strings.ToLower(part.Value) == "as" {
-			isBuilder = true
-		} else {
-			if isBuilder {
-				builderName = &part.Value
-				break
-			}
-			image = part.Value
-		}
-		part = part.Next
+	var builderName *string = nil
+	if stage.Name != "" {
+		builderName = &stage.Name
 	}
 
-	return handler(image, builderName)
+	return handler(stage.BaseName, builderName)
 }
 
 func validateIfLatest(image string, validationContext validations.ValidationContext, summary string) *validations.ValidationResult {
@@ -67,7 +59,7 @@ func noLatestBuilder() validations.Rule {
 		Priority: model.LowPriority,
 		Commands: targetCommands,
 		Handler: func(node *parser.Node, validationContext validations.ValidationContext) *validations.ValidationResult {
-			return processFrom(node, func(image string, builderName *string) *validations.ValidationResult {
+			return processFrom(node, validationContext, func(image string, builderName *string) *validations.ValidationResult {
 				if builderName == nil {
 					return validations.NewValidationResultSkipped("No builder reference found in the Dockerfile")
 				}
@@ -90,7 +82,7 @@ func noLatest() validations.Rule {
 		Priority: model.HighPriority,
 		Commands: targetCommands,
 		Handler: func(node *parser.Node, validationContext validations.ValidationContext) *validations.ValidationResult {
-			return processFrom(node, func(image string, builderName *string) *validations.ValidationResult {
+			return processFrom(node, validationContext, func(image string, builderName *string) *validations.ValidationResult {
 				if builderName != nil {
 					return validations.NewValidationResultSkipped("This rule does not apply to staged builds")
 				}
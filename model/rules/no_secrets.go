@@ -1,35 +1,163 @@
 package rules
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
 	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/secrets"
 	"github.com/jimschubert/docked/model/validations"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	genericNoSecretsSummary = "Secrets shouldn't be hard-coded. You should remove and rotate any secrets."
 )
 
-func noAwsAccessKey() validations.Rule {
-	// see https://aws.amazon.com/blogs/security/a-safer-way-to-distribute-aws-credentials-to-ec2/
-	r := validations.SimpleRegexRule{
-		Name:     "secret-aws-access-key",
-		Summary:  genericNoSecretsSummary,
-		Pattern:  `\bAK[A-Z0-9]{18}\b`,
-		Priority: model.CriticalPriority,
-		Command:  commands.Env,
+// awsProviders are reported within ENV by their dedicated rules, which predate hard-coded-secret
+var awsProviders = []string{"aws-access-key", "aws-secret-access-key"}
+
+// secretScan reports each secret found within the source of an instruction as a separate, sensitive context
+type secretScan struct {
+	// providers returns the providers to scan with according to the Settings of the analysis
+	providers func(settings Settings) []secrets.Provider
+	// exclude determines whether findings of provider within an instruction are left to another rule
+	exclude func(provider secrets.Provider, command commands.DockerCommand) bool
+}
+
+// configuredSecretProviders returns the built-in providers and those defined in Settings, less any disabled via Settings
+func configuredSecretProviders(settings Settings) []secrets.Provider {
+	config := settings.Secrets
+	providers := make([]secrets.Provider, 0)
+	for _, provider := range secrets.DefaultProviders() {
+		if !model.StringSliceContains(&config.Disable, provider.Name) {
+			providers = append(providers, provider)
+		}
+	}
+	for _, custom := range config.Providers {
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			log.Warnf("Ignoring secret provider %s with invalid pattern: %s", custom.Name, err)
+			continue
+		}
+		description := custom.Description
+		if description == "" {
+			description = custom.Name
+		}
+		providers = append(providers, secrets.Provider{
+			Name:        custom.Name,
+			Description: description,
+			Pattern:     pattern,
+			MinEntropy:  custom.MinEntropy,
+		})
+	}
+	return providers
+}
+
+func (s secretScan) evaluate(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	if mcr == nil || mcr.ContextCache == nil {
+		return validations.NewValidationResultSkipped(mcr.GetSummary())
+	}
+
+	detector := secrets.NewDetector(s.providers(ruleSettings(mcr))...)
+	found := make([]string, 0)
+	validationContexts := make([]validations.ValidationContext, 0)
+	for _, nodeContext := range *mcr.ContextCache {
+		source := nodeContext.Context.Source
+		if source == nil {
+			continue
+		}
+		command := commands.Of(nodeContext.Node.Value)
+		for i, line := range source.Lines {
+			for _, finding := range detector.Scan(line) {
+				if s.exclude != nil && s.exclude(finding.Provider, command) {
+					continue
+				}
+				found = append(found, fmt.Sprintf("%s (%s)", finding.Provider.Description, finding.Masked()))
+				validationContext := nodeContext.Context
+				validationContext.Line = finding.Masked()
+				validationContext.CausedFailure = true
+				validationContext.Sensitive = true
+				validationContext.Locations = []docker.Location{{
+					Start: docker.Position{Line: source.StartLine + i, Character: finding.Start},
+					End:   docker.Position{Line: source.StartLine + i, Character: finding.End},
+				}}
+				validationContexts = append(validationContexts, validationContext)
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return &validations.ValidationResult{
+			Result:  model.Success,
+			Details: mcr.GetSummary(),
+		}
+	}
+	return &validations.ValidationResult{
+		Result:   model.Failure,
+		Details:  fmt.Sprintf("%s Found: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+		Contexts: validationContexts,
+	}
+}
+
+// awsSecretRule creates a rule reporting the built-in AWS provider named provider within ENV
+func awsSecretRule(name string, provider string) validations.Rule {
+	scan := secretScan{
+		providers: func(settings Settings) []secrets.Provider {
+			config := settings.Secrets
+			if p, ok := secrets.DefaultProvider(provider); ok && !model.StringSliceContains(&config.Disable, provider) {
+				return []secrets.Provider{p}
+			}
+			return []secrets.Provider{}
+		},
+	}
+	r := validations.MultiContextRule{
+		Name:             name,
+		Summary:          genericNoSecretsSummary,
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Env},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: scan.evaluate,
+		},
 	}
 	return &r
 }
 
+func noAwsAccessKey() validations.Rule {
+	// see https://aws.amazon.com/blogs/security/a-safer-way-to-distribute-aws-credentials-to-ec2/
+	return awsSecretRule("secret-aws-access-key", "aws-access-key")
+}
+
 func noAwsSecretAccessKey() validations.Rule {
 	// see https://aws.amazon.com/blogs/security/a-safer-way-to-distribute-aws-credentials-to-ec2/
-	r := validations.SimpleRegexRule{
-		Name:     "secret-aws-secret-access-key",
-		Summary:  genericNoSecretsSummary,
-		Pattern:  `\b[A-Za-z0-9/+=]{40}\b`,
-		Priority: model.CriticalPriority,
-		Command:  commands.Env,
+	return awsSecretRule("secret-aws-secret-access-key", "aws-secret-access-key")
+}
+
+func hardCodedSecret() validations.Rule {
+	scan := secretScan{
+		providers: configuredSecretProviders,
+		exclude: func(provider secrets.Provider, command commands.DockerCommand) bool {
+			return command == commands.Env && model.StringSliceContains(&awsProviders, provider.Name)
+		},
+	}
+	r := validations.MultiContextRule{
+		Name:    "hard-coded-secret",
+		Summary: genericNoSecretsSummary,
+		Details: "Secrets within instructions are persisted in the Dockerfile, build history, and often the image itself. " +
+			"Tokens, keys, and passwords for GitHub, GitLab, Slack, Google Cloud, Azure, npm, private keys, JWTs, and URLs with credentials are detected. " +
+			"Use RUN --mount=type=secret instead. Providers may be disabled or added via settings.secrets.",
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Env, commands.Arg, commands.Label, commands.Run, commands.Add, commands.Copy},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/secrets/"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: scan.evaluate,
+		},
 	}
 	return &r
 }
@@ -37,4 +165,5 @@ func noAwsSecretAccessKey() validations.Rule {
 func init() {
 	AddRule(noAwsAccessKey())
 	AddRule(noAwsSecretAccessKey())
+	AddRule(hardCodedSecret())
 }
@@ -0,0 +1,118 @@
+package rules
+
+import (
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+)
+
+// defaultNonRootImages are base images known to run as a non-root user, as patterns compatible with path.Match
+var defaultNonRootImages = []string{
+	"gcr.io/distroless/*:nonroot",
+	"gcr.io/distroless/*:*-nonroot",
+	"cgr.dev/chainguard/static",
+	"cgr.dev/chainguard/static:*",
+	"bitnami/*",
+}
+
+// stageUser is the effective user of a build stage
+type stageUser struct {
+	// user is the value of the last USER instruction, or empty if the stage relies on its base image
+	user string
+	// nonRootBase is whether the base image is known to run as a non-root user
+	nonRootBase bool
+}
+
+// isRoot determines whether the stage runs as the root user
+func (s stageUser) isRoot() bool {
+	if s.user == "" {
+		return !s.nonRootBase
+	}
+	name, _, _ := strings.Cut(s.user, ":")
+	return name == "root" || name == "0"
+}
+
+// isNonRootImage determines whether image matches defaultNonRootImages or the images configured in Settings, or
+// whether the image catalog describes it as running as non-root
+func isNonRootImage(image string, settings Settings) bool {
+	if facts, ok := imageCatalog(settings).Lookup(image); ok && !facts.IsRoot() {
+		return true
+	}
+	image, _, _ = strings.Cut(strings.TrimPrefix(image, "docker.io/"), "@")
+	patterns := append(append([]string{}, defaultNonRootImages...), settings.NonRootImages...)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, image); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func nonRootUser() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "non-root-user",
+		Summary: "The final stage should run as a non-root user",
+		Details: "Running containers as root increases the impact of a compromised process. Add a USER instruction for a non-root user to the final stage, " +
+			"or build from an image known to run as non-root. Additional non-root base images may be configured via settings.non_root_images.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.User, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#user"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// builder stages are tracked only so a final stage built FROM a named stage inherits its user
+				stages := make(map[string]stageUser)
+				var stageName string
+				var current stageUser
+				finalContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					if stage := parsed.From(); stage != nil {
+						if inherited, ok := stages[strings.ToLower(stage.BaseName)]; ok {
+							current = inherited
+						} else {
+							current = stageUser{nonRootBase: isNonRootImage(stage.BaseName, settingsOf(nodeContext.Context))}
+						}
+						stageName = stage.Name
+					} else if user := parsed.User(); user != nil {
+						current.user = user.User
+					}
+					if stageName != "" {
+						stages[stageName] = current
+					}
+					if !nodeContext.Context.IsBuilderContext {
+						finalContexts = append(finalContexts, nodeContext.Context)
+					}
+				}
+
+				if len(finalContexts) == 0 || !current.isRoot() {
+					return &validations.ValidationResult{
+						Result:   model.Success,
+						Details:  mcr.GetSummary(),
+						Contexts: finalContexts,
+					}
+				}
+
+				// the last instruction (USER, or FROM without a USER) determines the user
+				finalContexts[len(finalContexts)-1].CausedFailure = true
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  mcr.GetSummary(),
+					Contexts: finalContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(nonRootUser())
+}
@@ -26,7 +26,11 @@ func openContainersAnnotations() validations.Rule {
 				result := model.Recommendation
 				validationContexts := make([]validations.ValidationContext, 0)
 				for _, nodeValidationContext := range *mcr.ContextCache {
-					if strings.Contains(nodeValidationContext.Node.Original, "org.opencontainers") {
+					annotated := false
+					for _, label := range labelsOf(nodeValidationContext) {
+						annotated = annotated || strings.HasPrefix(label.Key, "org.opencontainers.")
+					}
+					if annotated {
 						result = model.Success
 					} else {
 						nodeValidationContext.Context.HasRecommendations = true
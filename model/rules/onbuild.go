@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func invalidOnbuildTrigger() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "invalid-onbuild-trigger",
+		Summary: "ONBUILD may not trigger ONBUILD, FROM, or MAINTAINER",
+		Details: "Chaining ONBUILD instructions using ONBUILD ONBUILD isn't allowed, and ONBUILD may not trigger FROM or MAINTAINER instructions. " +
+			"Builds of images based on this image will fail.",
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Onbuild},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#onbuild-limitations"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				trigger := docker.OnbuildTrigger(node)
+				if trigger == nil {
+					return model.Skipped
+				}
+				if docker.IsForbiddenOnbuildTrigger(commands.Of(trigger.Value)) {
+					return model.Failure
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func onbuildFinalImage() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "onbuild-final-image",
+		Summary: "Avoid ONBUILD in final images",
+		Details: "ONBUILD triggers run implicitly during builds of images which use this image as a base, " +
+			"hiding build steps from the downstream Dockerfile. Prefer documenting the steps or using a dedicated builder image.",
+		Priority: model.MediumPriority,
+		Commands: []commands.DockerCommand{commands.Onbuild},
+		URL:      model.StringPtr("https://docs.docker.com/reference/dockerfile/#onbuild"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				return model.Recommendation
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(invalidOnbuildTrigger())
+	AddRule(onbuildFinalImage())
+}
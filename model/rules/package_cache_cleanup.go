@@ -0,0 +1,214 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// packageCache describes where a package manager leaves its cache, and how that cache is avoided or removed
+type packageCache struct {
+	// managers detects the package manager's install commands
+	managers model.PredicateMap
+	// paths are the cache directories, which may be removed via rm or mounted via RUN --mount=type=cache
+	paths []string
+	// noCacheFlags are install flags which avoid writing the cache
+	noCacheFlags []string
+	// isClean determines whether a command cleans the cache, e.g. yum clean all
+	isClean func(name string, args []string) bool
+}
+
+// covers determines whether path (e.g. a target of rm or --mount) is cachePath or one of its parent directories.
+// Subdirectories of cachePath, such as /var/lib/apt/lists/partial, leave the remainder of the cache in place.
+func covers(path string, cachePath string) bool {
+	path = strings.TrimRight(strings.TrimSuffix(path, "*"), "/")
+	if path == "" || path == "~" {
+		return false
+	}
+	return isWithin(cachePath, path)
+}
+
+// isCacheMounted determines whether a RUN --mount=type=cache targets one of the cache paths,
+// in which case the cache is not persisted to the layer and needs no cleanup
+func (p packageCache) isCacheMounted(mounts []*instructions.Mount) bool {
+	for _, mount := range mounts {
+		if mount == nil || mount.Type != instructions.MountTypeCache {
+			continue
+		}
+		for _, path := range p.paths {
+			if covers(mount.Target, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCleanup determines whether command removes the cache
+func (p packageCache) isCleanup(command shell.PosixCommand) bool {
+	name := strings.TrimLeft(command.Name, `\`)
+	if name == "rm" {
+		for _, arg := range command.Args {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			for _, path := range p.paths {
+				if covers(arg, path) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return p.isClean != nil && p.isClean(name, command.Args)
+}
+
+// avoidsCache determines whether the install command was invoked with a flag which prevents caching
+func (p packageCache) avoidsCache(command shell.PosixCommand) bool {
+	for _, arg := range command.Args {
+		if model.StringSliceContains(&p.noCacheFlags, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate checks that each install within the RUN instruction cleans up after itself
+func (p packageCache) evaluate(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+	if p.isCacheMounted(instructionOf(node, validationContext).Flags.Mounts) {
+		return model.Success
+	}
+
+	posixCommands, err := runCommands(node, validationContext)
+	if err != nil {
+		return model.Skipped
+	}
+
+	pendingCleanup := false
+	for _, command := range posixCommands {
+		if _, ok := findInstallCommand(command, p.managers); ok {
+			if !p.avoidsCache(command) {
+				pendingCleanup = true
+			}
+			continue
+		}
+		if pendingCleanup && p.isCleanup(command) {
+			pendingCleanup = false
+		}
+	}
+
+	if pendingCleanup {
+		return model.Recommendation
+	}
+	return model.Success
+}
+
+func cacheCleanupRule(name string, summary string, details string, url string, cache packageCache) validations.Rule {
+	r := validations.MultiContextRule{
+		Name:             name,
+		Summary:          summary,
+		Details:          details + " Alternatively, use RUN --mount=type=cache to keep the cache out of the image.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr(url),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn:          cache.evaluate,
+			Unevaluated: unevaluatedRun,
+		},
+	}
+	return &r
+}
+
+func aptCacheCleanup() validations.Rule {
+	return cacheCleanupRule(
+		"apt-cache-cleanup",
+		"Remove apt package lists in the same RUN as apt-get install",
+		"Package lists downloaded by apt-get update are persisted in the layer unless removed in the same RUN instruction, via rm -rf /var/lib/apt/lists/*.",
+		"https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#apt-get",
+		packageCache{
+			managers: managerLookup("apt", "apt-get"),
+			paths:    []string{"/var/lib/apt/lists"},
+		},
+	)
+}
+
+func apkCacheCleanup() validations.Rule {
+	return cacheCleanupRule(
+		"apk-cache-cleanup",
+		"Use apk add --no-cache or remove the apk cache in the same RUN",
+		"apk add --no-cache avoids persisting the package index and cached packages in the layer, without requiring a separate cleanup.",
+		"https://wiki.alpinelinux.org/wiki/Local_APK_cache",
+		packageCache{
+			managers:     managerLookup("apk"),
+			paths:        []string{"/var/cache/apk", "/etc/apk/cache"},
+			noCacheFlags: []string{"--no-cache"},
+			isClean: func(name string, args []string) bool {
+				return name == "apk" && len(args) > 1 && args[0] == "cache" && args[1] == "clean"
+			},
+		},
+	)
+}
+
+func yumCacheCleanup() validations.Rule {
+	isClean := func(name string, args []string) bool {
+		return (name == "yum" || name == "dnf" || name == "microdnf") && len(args) > 1 && args[0] == "clean" && args[1] == "all"
+	}
+	return cacheCleanupRule(
+		"yum-cache-cleanup",
+		"Run yum clean all, dnf clean all, or microdnf clean all in the same RUN as install",
+		"yum and dnf cache package metadata and packages, which are persisted in the layer unless cleaned in the same RUN instruction.",
+		"https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#run",
+		packageCache{
+			managers: managerLookup("yum", "dnf", "microdnf"),
+			paths:    []string{"/var/cache/yum", "/var/cache/dnf"},
+			isClean:  isClean,
+		},
+	)
+}
+
+func pipCacheCleanup() validations.Rule {
+	return cacheCleanupRule(
+		"pip-cache-cleanup",
+		"Use pip install --no-cache-dir",
+		"pip caches downloaded packages and wheels under ~/.cache/pip, which is persisted in the layer unless pip install is invoked with --no-cache-dir.",
+		"https://pip.pypa.io/en/stable/topics/caching/",
+		packageCache{
+			managers:     managerLookup("pip", "pip3"),
+			paths:        []string{"/root/.cache/pip", "~/.cache/pip"},
+			noCacheFlags: []string{"--no-cache-dir"},
+			isClean: func(name string, args []string) bool {
+				return (name == "pip" || name == "pip3") && len(args) > 1 && args[0] == "cache" && args[1] == "purge"
+			},
+		},
+	)
+}
+
+func npmCacheCleanup() validations.Rule {
+	return cacheCleanupRule(
+		"npm-cache-cleanup",
+		"Run npm cache clean --force in the same RUN as npm install",
+		"npm caches downloaded packages under ~/.npm, which is persisted in the layer unless cleaned in the same RUN instruction.",
+		"https://docs.npmjs.com/cli/commands/npm-cache",
+		packageCache{
+			managers: managerLookup("npm"),
+			paths:    []string{"/root/.npm", "~/.npm"},
+			isClean: func(name string, args []string) bool {
+				return name == "npm" && len(args) > 1 && args[0] == "cache" && args[1] == "clean"
+			},
+		},
+	)
+}
+
+func init() {
+	AddRule(aptCacheCleanup())
+	AddRule(apkCacheCleanup())
+	AddRule(yumCacheCleanup())
+	AddRule(pipCacheCleanup())
+	AddRule(npmCacheCleanup())
+}
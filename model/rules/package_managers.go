@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/shell"
+)
+
+// packageManager describes the command line of a package manager
+type packageManager struct {
+	// isInstall determines whether a subcommand installs packages
+	isInstall func(subcommand string) bool
+	// valueFlags are flags which consume the following argument, e.g. apk add --virtual .build-deps
+	valueFlags []string
+}
+
+// packageManagers are the known package managers, by executable name. Add an entry here to support
+// a package manager in all rules which evaluate installed packages.
+var packageManagers = map[string]packageManager{
+	// see https://manpages.ubuntu.com/manpages/xenial/man8/apt.8.html
+	"apt": {
+		isInstall:  isOneOf("install"),
+		valueFlags: []string{"-o", "-t", "-c", "--option", "--target-release", "--config-file"},
+	},
+	// see https://linux.die.net/man/8/apt-get
+	"apt-get": {
+		isInstall:  isOneOf("install"),
+		valueFlags: []string{"-o", "-t", "-c", "--option", "--target-release", "--config-file"},
+	},
+	// see https://man7.org/linux/man-pages/man8/yum.8.html
+	"yum": {
+		isInstall:  isOneOf("install"),
+		valueFlags: []string{"-c", "--config", "--installroot", "--releasever", "--enablerepo", "--disablerepo"},
+	},
+	// see https://dnf.readthedocs.io/en/latest/command_ref.html
+	"dnf": {
+		isInstall:  isOneOf("install", "in"),
+		valueFlags: []string{"-c", "--config", "--installroot", "--releasever", "--enablerepo", "--disablerepo", "--setopt"},
+	},
+	// see https://github.com/rpm-software-management/microdnf
+	"microdnf": {
+		isInstall:  isOneOf("install"),
+		valueFlags: []string{"--config", "--installroot", "--releasever", "--enablerepo", "--disablerepo", "--setopt"},
+	},
+	// see https://en.opensuse.org/SDB:Zypper_manual
+	"zypper": {
+		isInstall:  isOneOf("install", "in"),
+		valueFlags: []string{"-r", "-t", "--repo", "--type", "--root"},
+	},
+	// see https://wiki.alpinelinux.org/wiki/Alpine_Linux_package_management#Add_a_Package
+	// NOTE: apk switches can come _after_ packages
+	"apk": {
+		isInstall:  isOneOf("add"),
+		valueFlags: []string{"-t", "-X", "-p", "--virtual", "--repository", "--root"},
+	},
+	// see https://docs.npmjs.com/cli/v7/commands/npm-install
+	"npm": {
+		isInstall:  isOneOf("install", "i", "ci", "add", "isntall"),
+		valueFlags: []string{"--prefix", "--registry", "--cache"},
+	},
+	// see https://pip.pypa.io/en/stable/cli/pip_install/
+	"pip": {
+		isInstall:  isOneOf("install"),
+		valueFlags: pipValueFlags,
+	},
+	"pip3": {
+		isInstall:  isOneOf("install"),
+		valueFlags: pipValueFlags,
+	},
+	// see https://guides.rubygems.org/command-reference/#gem-install
+	"gem": {
+		isInstall:  isOneOf("install", "i"),
+		valueFlags: []string{"-v", "-i", "-n", "--version", "--install-dir", "--bindir", "--source"},
+	},
+	// see https://doc.rust-lang.org/cargo/commands/cargo-install.html
+	"cargo": {
+		isInstall:  isOneOf("install"),
+		valueFlags: []string{"--version", "--vers", "--git", "--branch", "--tag", "--rev", "--path", "--root", "--index", "--registry"},
+	},
+}
+
+var pipValueFlags = []string{"-r", "-c", "-e", "-i", "-t", "--requirement", "--constraint", "--editable", "--index-url", "--extra-index-url", "--target", "--prefix", "--root"}
+
+// isOneOf creates a predicate matching any of the subcommands
+func isOneOf(subcommands ...string) func(string) bool {
+	return func(s string) bool {
+		return model.StringSliceContains(&subcommands, s)
+	}
+}
+
+// installIndicators is the lookup of install subcommands for all known packageManagers
+func installIndicators() model.PredicateMap {
+	commandLookup := model.PredicateMap{}
+	for name, manager := range packageManagers {
+		commandLookup[name] = manager.isInstall
+	}
+	return commandLookup
+}
+
+// managerLookup is the lookup of install subcommands for the named packageManagers
+func managerLookup(names ...string) model.PredicateMap {
+	commandLookup := model.PredicateMap{}
+	for _, name := range names {
+		if manager, ok := packageManagers[name]; ok {
+			commandLookup[name] = manager.isInstall
+		}
+	}
+	return commandLookup
+}
+
+// installCommand is a package manager invocation which installs packages
+type installCommand struct {
+	Manager  string
+	Packages []string
+	// PackageIndexes are the indexes of Packages within the command's Args
+	PackageIndexes []int
+}
+
+// findInstallCommand determines whether command invokes the install command of a package manager in commandLookup,
+// returning the manager and any packages installed. Values of the manager's flags (see packageManager.valueFlags)
+// are not considered packages.
+func findInstallCommand(command shell.PosixCommand, commandLookup model.PredicateMap) (*installCommand, bool) {
+	managers := commandLookup.Keys()
+	var name string
+	var argIndexStart = 0
+	name = strings.TrimLeft(command.Name, `\`)
+	// this is a naive "best-guess" means to support finding package manager in some edge-cases
+	if name == "sudo" || name == "su" || name == "gosu" {
+		for idx, arg := range command.Args {
+			if !strings.HasPrefix(arg, "-") {
+				name = strings.TrimLeft(arg, `\`)
+				argIndexStart = idx
+				break
+			}
+		}
+	}
+
+	if !model.StringSliceContains(&managers, name) {
+		return nil, false
+	}
+	valueFlags := packageManagers[name].valueFlags
+
+	// We assume all commands are format:
+	// package-manager [options] <command> [<args>...]
+	// we need to find the command, then evaluate the args
+	var seenInstallCommand bool
+	var skipNext bool
+	install := installCommand{Manager: name, Packages: make([]string, 0), PackageIndexes: make([]int, 0)}
+	for idx := argIndexStart; idx < len(command.Args); idx++ {
+		arg := command.Args[idx]
+		if skipNext {
+			skipNext = false
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			skipNext = model.StringSliceContains(&valueFlags, arg)
+			continue
+		}
+		if !seenInstallCommand {
+			seenInstallCommand = commandLookup[name](arg)
+			continue
+		}
+		install.Packages = append(install.Packages, arg)
+		install.PackageIndexes = append(install.PackageIndexes, idx)
+	}
+
+	if !seenInstallCommand {
+		return nil, false
+	}
+	return &install, true
+}
@@ -0,0 +1,236 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+)
+
+// floatingVersions are version queries which resolve to a different version over time
+var floatingVersions = []string{"latest", "next", "upgrade", "patch"}
+
+// packagePinning describes how a package manager's install command pins package versions
+type packagePinning struct {
+	// managers detects the package manager's install commands
+	managers model.PredicateMap
+	// isPinned determines whether pkg defines its version; args are all arguments of the install command
+	isPinned func(pkg string, args []string) bool
+}
+
+// isLocalPackage determines whether pkg refers to a local path, archive, or URL rather than a package name
+func isLocalPackage(pkg string) bool {
+	if strings.HasPrefix(pkg, ".") || strings.HasPrefix(pkg, "/") || strings.HasPrefix(pkg, "~") || strings.Contains(pkg, "://") {
+		return true
+	}
+	for _, extension := range []string{".deb", ".rpm", ".apk", ".whl", ".tar.gz", ".tgz", ".gem", ".zip"} {
+		if strings.HasSuffix(pkg, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionAfter returns the version following the last separator in pkg, ignoring a leading separator (e.g. npm's @scope/name)
+func versionAfter(pkg string, separator string) (string, bool) {
+	idx := strings.LastIndex(pkg, separator)
+	if idx <= 0 {
+		return "", false
+	}
+	return pkg[idx+len(separator):], true
+}
+
+// isAllowedUnpinned determines whether the package may be installed without a version according to Settings
+func isAllowedUnpinned(manager string, pkg string, settings Settings) bool {
+	allowed := settings.PackagePinning.Allow
+	names := []string{pkg}
+	if idx := strings.LastIndex(pkg, "@"); idx > 0 {
+		names = append(names, pkg[:idx])
+	}
+	for _, name := range names {
+		if model.StringSliceContains(&allowed, name) || model.StringSliceContains(&allowed, manager+":"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate reports each unpinned package as a separate context, located at the package argument
+func (p packagePinning) evaluate(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	if mcr == nil || mcr.ContextCache == nil {
+		return validations.NewValidationResultSkipped(mcr.GetSummary())
+	}
+
+	unpinned := make([]string, 0)
+	validationContexts := make([]validations.ValidationContext, 0)
+	unevaluated := unevaluatedRuns{}
+	for _, nodeContext := range *mcr.ContextCache {
+		posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+		if err != nil {
+			unevaluated.add(nodeContext.Context, err)
+			continue
+		}
+		for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+			install, ok := findInstallCommand(command.PosixCommand, p.managers)
+			if !ok {
+				continue
+			}
+			for i, pkg := range install.Packages {
+				if pkg == "" || isLocalPackage(pkg) || p.isPinned(pkg, command.Args) || isAllowedUnpinned(install.Manager, pkg, settingsOf(nodeContext.Context)) {
+					continue
+				}
+				unpinned = append(unpinned, pkg)
+				validationContext := nodeContext.Context
+				validationContext.Line = pkg
+				validationContext.HasRecommendations = true
+				if location := command.ArgLocations[install.PackageIndexes[i]]; location.Start.Line > 0 {
+					validationContext.Locations = []docker.Location{location}
+				}
+				validationContexts = append(validationContexts, validationContext)
+			}
+		}
+	}
+
+	if len(unpinned) == 0 {
+		return unevaluated.result(&validations.ValidationResult{
+			Result:  model.Success,
+			Details: mcr.GetSummary(),
+		})
+	}
+	return &validations.ValidationResult{
+		Result:   model.Recommendation,
+		Details:  fmt.Sprintf("%s Unpinned: %s", mcr.GetSummary(), strings.Join(unpinned, ", ")),
+		Contexts: validationContexts,
+	}
+}
+
+func pinningRule(name string, summary string, details string, url string, pinning packagePinning) validations.Rule {
+	r := validations.MultiContextRule{
+		Name:             name,
+		Summary:          summary,
+		Details:          details + " Packages which intentionally float may be allowed via settings.package_pinning.allow.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr(url),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: pinning.evaluate,
+		},
+	}
+	return &r
+}
+
+func aptPinVersions() validations.Rule {
+	return pinningRule(
+		"apt-pin-versions",
+		"Pin versions of packages installed with apt-get (e.g. curl=7.88.*)",
+		"Unpinned packages install whichever version is current at build time, so rebuilding the same Dockerfile may produce a different image.",
+		"https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#apt-get",
+		packagePinning{
+			managers: managerLookup("apt", "apt-get"),
+			isPinned: func(pkg string, _ []string) bool {
+				return strings.Contains(pkg, "=")
+			},
+		},
+	)
+}
+
+func apkPinVersions() validations.Rule {
+	return pinningRule(
+		"apk-pin-versions",
+		"Pin versions of packages installed with apk (e.g. curl=8.5.0-r0)",
+		"Unpinned packages install whichever version is current at build time, so rebuilding the same Dockerfile may produce a different image.",
+		"https://wiki.alpinelinux.org/wiki/Alpine_Package_Keeper#Add_a_Package",
+		packagePinning{
+			managers: managerLookup("apk"),
+			isPinned: func(pkg string, _ []string) bool {
+				return strings.ContainsAny(pkg, "=~")
+			},
+		},
+	)
+}
+
+func pipPinVersions() validations.Rule {
+	return pinningRule(
+		"pip-pin-versions",
+		"Pin versions of packages installed with pip (e.g. requests==2.31.0)",
+		"Unpinned packages install whichever version is current at build time, so rebuilding the same Dockerfile may produce a different image. "+
+			"Prefer installing from a requirements file with pinned versions.",
+		"https://pip.pypa.io/en/stable/topics/repeatable-installs/",
+		packagePinning{
+			managers: managerLookup("pip", "pip3"),
+			isPinned: func(pkg string, _ []string) bool {
+				return strings.Contains(pkg, "==")
+			},
+		},
+	)
+}
+
+func npmPinVersions() validations.Rule {
+	return pinningRule(
+		"npm-pin-versions",
+		"Pin versions of packages installed with npm (e.g. typescript@5.4.5)",
+		"Unpinned packages install whichever version is current at build time, so rebuilding the same Dockerfile may produce a different image.",
+		"https://docs.npmjs.com/cli/commands/npm-install",
+		packagePinning{
+			managers: managerLookup("npm"),
+			isPinned: func(pkg string, _ []string) bool {
+				version, ok := versionAfter(pkg, "@")
+				return ok && version != "" && !model.StringSliceContains(&floatingVersions, version)
+			},
+		},
+	)
+}
+
+func goInstallVersion() validations.Rule {
+	return pinningRule(
+		"go-install-version",
+		"Pin versions of modules installed with go install (e.g. golang.org/x/tools/gopls@v0.15.3)",
+		"go install with @latest installs whichever version is current at build time, so rebuilding the same Dockerfile may produce a different image.",
+		"https://go.dev/ref/mod#go-install",
+		packagePinning{
+			managers: model.PredicateMap{"go": func(s string) bool { return s == "install" }},
+			isPinned: func(pkg string, _ []string) bool {
+				version, ok := versionAfter(pkg, "@")
+				if !ok {
+					// without a version, go install builds from the current module's go.mod
+					return true
+				}
+				return version != "" && !model.StringSliceContains(&floatingVersions, version)
+			},
+		},
+	)
+}
+
+func gemPinVersions() validations.Rule {
+	return pinningRule(
+		"gem-pin-versions",
+		"Pin versions of gems installed with gem install (e.g. gem install rails -v 7.1.3)",
+		"Unpinned gems install whichever version is current at build time, so rebuilding the same Dockerfile may produce a different image.",
+		"https://guides.rubygems.org/command-reference/#gem-install",
+		packagePinning{
+			managers: managerLookup("gem"),
+			isPinned: func(pkg string, args []string) bool {
+				for _, arg := range args {
+					if arg == "-v" || arg == "--version" || strings.HasPrefix(arg, "--version=") {
+						return true
+					}
+				}
+				return strings.Contains(pkg, ":")
+			},
+		},
+	)
+}
+
+func init() {
+	AddRule(aptPinVersions())
+	AddRule(apkPinVersions())
+	AddRule(pipPinVersions())
+	AddRule(npmPinVersions())
+	AddRule(goInstallVersion())
+	AddRule(gemPinVersions())
+}
@@ -0,0 +1,328 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+// fileMode describes the permissions granted by a mode of chmod, mkdir -m, or COPY --chmod
+type fileMode struct {
+	// worldWritable is whether any user may write to the file
+	worldWritable bool
+	// sticky is whether only owners may delete or rename files within the directory
+	sticky bool
+	// setuid is whether the file runs as its owner or group (setuid or setgid)
+	setuid bool
+}
+
+// parseMode parses a mode in octal (0755) or symbolic (u+x,go-w) notation. Symbolic modes without users (+w) depend
+// on the umask, so aren't considered to grant permissions to other users.
+func parseMode(mode string) (fileMode, bool) {
+	if octal, err := strconv.ParseUint(mode, 8, 32); err == nil && len(mode) <= 4 {
+		return fileMode{worldWritable: octal&0o002 != 0, sticky: octal&0o1000 != 0, setuid: octal&0o6000 != 0}, true
+	}
+
+	parsed := fileMode{}
+	for _, clause := range strings.Split(mode, ",") {
+		idx := strings.IndexAny(clause, "+-=")
+		if idx < 0 || strings.Trim(clause[:idx], "ugoa") != "" {
+			return fileMode{}, false
+		}
+		who := clause[:idx]
+		var operator rune
+		for _, c := range clause[idx:] {
+			switch {
+			case strings.ContainsRune("+-=", c):
+				operator = c
+			case operator == '-':
+				// permissions removed (go-w) aren't granted
+			case c == 'w':
+				parsed.worldWritable = parsed.worldWritable || strings.ContainsAny(who, "oa")
+			case c == 's':
+				parsed.setuid = parsed.setuid || who == "" || strings.ContainsAny(who, "uga")
+			case c == 't':
+				parsed.sticky = true
+			}
+		}
+	}
+	return parsed, true
+}
+
+// fileCommandArgs splits the arguments of chmod or chown into the mode or owner and the files, and whether the command is recursive
+func fileCommandArgs(args []string) (string, []string, bool) {
+	var recursive bool
+	for i, arg := range args {
+		switch {
+		case arg == "--recursive":
+			recursive = true
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && strings.Trim(arg[1:], "RcfvhHLP") == "" && len(arg) > 1:
+			recursive = recursive || strings.Contains(arg, "R")
+		default:
+			return arg, args[i+1:], recursive
+		}
+	}
+	return "", nil, recursive
+}
+
+// createdDirectoryMode finds the mode of directories created by mkdir -m or install -d -m
+func createdDirectoryMode(command locatedCommand) (string, bool) {
+	name := commandName(&command.PosixCommand)
+	if name != "mkdir" && name != "install" {
+		return "", false
+	}
+	var mode string
+	var directory bool
+	for i, arg := range command.Args {
+		switch {
+		case arg == "-m" || arg == "--mode":
+			if i+1 < len(command.Args) {
+				mode = command.Args[i+1]
+			}
+		case strings.HasPrefix(arg, "--mode="):
+			mode = strings.TrimPrefix(arg, "--mode=")
+		case strings.HasPrefix(arg, "-m"):
+			mode = strings.TrimPrefix(arg, "-m")
+		case arg == "-d" || arg == "--directory":
+			directory = true
+		}
+	}
+	return mode, mode != "" && (name == "mkdir" || directory)
+}
+
+// commandSpan is the location from the name of command to its last argument
+func commandSpan(command locatedCommand) docker.Location {
+	span := command.NameLocation
+	for _, location := range command.ArgLocations {
+		if location != (docker.Location{}) {
+			span.End = location.End
+		}
+	}
+	return span
+}
+
+// modeCheck evaluates the modes set by chmod in RUN and by ADD/COPY --chmod
+type modeCheck func(mode fileMode) bool
+
+// find locates the modes matching the check within an instruction, recording RUN instructions which can't be evaluated
+func (check modeCheck) find(nodeContext validations.NodeValidationContext, unevaluated *unevaluatedRuns) []scriptFinding {
+	parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+	if parsed.Copy() != nil || parsed.Add() != nil {
+		mode, ok := parseMode(parsed.Flags.Chmod)
+		if parsed.Flags.Chmod == "" || !ok || !check(mode) {
+			return nil
+		}
+		flag := "--chmod=" + parsed.Flags.Chmod
+		location, _ := nodeContext.Context.Source.Find(flag, docker.Position{})
+		return []scriptFinding{{Location: location, Text: flag}}
+	}
+
+	posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+	if err != nil {
+		unevaluated.add(nodeContext.Context, err)
+		return nil
+	}
+	findings := make([]scriptFinding, 0)
+	for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+		if commandName(&command.PosixCommand) != "chmod" {
+			continue
+		}
+		modeArg, files, _ := fileCommandArgs(command.Args)
+		if mode, ok := parseMode(modeArg); ok && check(mode) {
+			findings = append(findings, scriptFinding{
+				Location: commandSpan(command),
+				Text:     strings.TrimSpace(fmt.Sprintf("chmod %s %s", modeArg, strings.Join(files, " "))),
+			})
+		}
+	}
+	return findings
+}
+
+// evaluate applies the check to each instruction, failing for any findings
+func (check modeCheck) evaluate(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	unevaluated := unevaluatedRuns{}
+	return unevaluated.result(runFindings(mcr, model.Failure, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+		return check.find(nodeContext, &unevaluated)
+	}))
+}
+
+func worldWritablePermissions() validations.Rule {
+	check := modeCheck(func(mode fileMode) bool {
+		// shared directories such as /tmp are world-writable with the sticky bit (1777)
+		return mode.worldWritable && !mode.sticky
+	})
+	r := validations.MultiContextRule{
+		Name:    "world-writable-permissions",
+		Summary: "Avoid granting write permission to all users (chmod 777, a+w)",
+		Details: "Files writable by any user may be modified by any process in the container, e.g. to replace an executable run by root. " +
+			"Grant write permission only to the owner (e.g. 755 or 644), and set ownership with COPY --chown.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.Copy, commands.Add},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#copy---chown---chmod"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate,
+		},
+	}
+	return &r
+}
+
+func setuidPermissions() validations.Rule {
+	check := modeCheck(func(mode fileMode) bool {
+		return mode.setuid
+	})
+	r := validations.MultiContextRule{
+		Name:    "setuid-permissions",
+		Summary: "Avoid setting the setuid or setgid bits",
+		Details: "Executables with the setuid or setgid bit run as their owner or group regardless of the user, so are a common means of privilege escalation. " +
+			"Grant the specific capability required instead, or run the process as the required user.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.Copy, commands.Add},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://cheatsheetseries.owasp.org/cheatsheets/Docker_Security_Cheat_Sheet.html#rule-4-prevent-in-container-privilege-escalation"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate,
+		},
+	}
+	return &r
+}
+
+func recursiveOwnershipAfterCopy() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "recursive-ownership-after-copy",
+		Summary: "Use COPY --chown or --chmod rather than changing copied files with chown -R or chmod -R",
+		Details: "Changing the ownership or mode of copied files in a later RUN duplicates every changed file in a new layer, which may double the size of large trees. " +
+			"Set ownership and mode as the files are copied with COPY --chown=user:group and --chmod.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run, commands.Copy, commands.Add, commands.Workdir, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#copy---chown---chmod"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// findings by the line of their RUN instruction
+				findings := make(map[int][]scriptFinding)
+				unevaluated := unevaluatedRuns{}
+				for _, stage := range stagesOf(*mcr.ContextCache) {
+					workdir := "/"
+					destinations := make([]string, 0)
+					for i := range stage {
+						nodeContext := &stage[i]
+						parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+						var sourcesAndDest *instructions.SourcesAndDest
+						if copyCommand := parsed.Copy(); copyCommand != nil {
+							sourcesAndDest = &copyCommand.SourcesAndDest
+						} else if addCommand := parsed.Add(); addCommand != nil {
+							sourcesAndDest = &addCommand.SourcesAndDest
+						}
+						switch {
+						case parsed.Workdir() != nil:
+							workdir = resolvePath(workdir, parsed.Workdir().Path)
+						case sourcesAndDest != nil:
+							if !isVariablePath(sourcesAndDest.DestPath) {
+								destinations = append(destinations, resolvePath(workdir, sourcesAndDest.DestPath))
+							}
+						case parsed.Run() != nil && len(destinations) > 0:
+							posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+							if err != nil {
+								unevaluated.add(nodeContext.Context, err)
+								continue
+							}
+							for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+								name := commandName(&command.PosixCommand)
+								if name != "chown" && name != "chmod" {
+									continue
+								}
+								_, files, recursive := fileCommandArgs(command.Args)
+								if !recursive || !changesCopiedFiles(files, destinations, workdir) {
+									continue
+								}
+								findings[nodeContext.Node.StartLine] = append(findings[nodeContext.Node.StartLine], scriptFinding{
+									Location: commandSpan(command),
+									Text:     fmt.Sprintf("%s -R %s", name, strings.Join(files, " ")),
+								})
+							}
+						}
+					}
+				}
+
+				return unevaluated.result(runFindings(mcr, model.Recommendation, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+					return findings[nodeContext.Node.StartLine]
+				}))
+			},
+		},
+	}
+	return &r
+}
+
+// changesCopiedFiles determines whether any of files is, contains, or is within the destinations of earlier COPY or ADD instructions
+func changesCopiedFiles(files []string, destinations []string, workdir string) bool {
+	for _, file := range files {
+		if isVariablePath(file) {
+			continue
+		}
+		file = resolvePath(workdir, file)
+		for _, destination := range destinations {
+			if file != "/" && (isWithin(file, destination) || isWithin(destination, file)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func worldWritableDirectory() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "world-writable-directory",
+		Summary: "Directories in the final stage should not be writable by all users without the sticky bit",
+		Details: "Any process in the container may add, replace, or delete files within a world-writable directory. " +
+			"Create directories owned by the runtime user instead (e.g. mkdir -p /data && chown app /data), or add the sticky bit (1777) for shared directories like /tmp.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://www.gnu.org/software/coreutils/manual/html_node/Mode-Structure.html"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				unevaluated := unevaluatedRuns{}
+				return unevaluated.result(runFindings(mcr, model.Failure, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+					posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+					if err != nil {
+						unevaluated.add(nodeContext.Context, err)
+						return nil
+					}
+					findings := make([]scriptFinding, 0)
+					for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+						modeArg, ok := createdDirectoryMode(command)
+						if !ok {
+							continue
+						}
+						if mode, valid := parseMode(modeArg); valid && mode.worldWritable && !mode.sticky {
+							findings = append(findings, scriptFinding{Location: commandSpan(command), Text: fmt.Sprintf("%s -m %s", command.Name, modeArg)})
+						}
+					}
+					return findings
+				}))
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(worldWritablePermissions())
+	AddRule(setuidPermissions())
+	AddRule(recursiveOwnershipAfterCopy())
+	AddRule(worldWritableDirectory())
+}
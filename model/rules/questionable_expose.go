@@ -1,20 +1,17 @@
 package rules
 
 import (
-	"bytes"
 	"fmt"
 
 	"github.com/jimschubert/docked/model"
-	"github.com/jimschubert/docked/model/docker"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/docker/types"
 	"github.com/jimschubert/docked/model/validations"
-	"github.com/moby/buildkit/frontend/dockerfile/parser"
-	"github.com/sirupsen/logrus"
 )
 
 var (
-	commonPorts = types.ExposeList{
+	// defaultDeniedPorts are sensitive ports denied unless replaced via ExposeSettings.Deny
+	defaultDeniedPorts = types.ExposeList{
 		types.Expose{PortRange: singlePort(21), Description: model.StringPtr("FTP")},
 		types.Expose{PortRange: singlePort(22), Description: model.StringPtr("SSH")},
 		types.Expose{PortRange: singlePort(23), Description: model.StringPtr("Telnet")},
@@ -29,7 +26,7 @@ var (
 		types.Expose{PortRange: singlePort(873), Description: model.StringPtr("rsync")},
 
 		types.Expose{PortRange: singlePort(993), Description: model.StringPtr("IMAP SSL")},
-		types.Expose{PortRange: singlePort(955), Description: model.StringPtr("POP3 SSL")},
+		types.Expose{PortRange: singlePort(995), Description: model.StringPtr("POP3 SSL")},
 		types.Expose{PortRange: singlePort(1080), Description: model.StringPtr("SOCKS")},
 		types.Expose{PortRange: singlePort(3128), Description: model.StringPtr("Proxy List")},
 		types.Expose{PortRange: singlePort(3306), Description: model.StringPtr("MySQL")},
@@ -44,51 +41,53 @@ func singlePort(input int) types.PortRange {
 	return types.PortRange{Start: input, End: input}
 }
 
-func questionableExpose() validations.Rule {
-	portDescriptionFormatted := func(e types.Expose) string {
-		buf := bytes.Buffer{}
-		buf.WriteString(fmt.Sprintf("%d", e.PortRange.Start))
-		if e.PortRange.End > e.PortRange.Start {
-			buf.WriteString(fmt.Sprintf("-%d", e.PortRange.End))
-		}
-		if e.Protocol != "" {
-			buf.WriteString(fmt.Sprintf("/%s", e.Protocol))
-		}
-		if e.Description != nil {
-			buf.WriteString(fmt.Sprintf(" (%s)", *e.Description))
-		}
-		return buf.String()
+// deniedPorts are the ports configured via ExposeSettings.Deny, or defaultDeniedPorts when not configured
+func deniedPorts(settings Settings) types.ExposeList {
+	if deny := settings.Expose.Deny; deny != nil {
+		return policyPorts("deny", deny)
 	}
+	return defaultDeniedPorts
+}
 
-	r := validations.MultiContextRule{
-		Name:     "questionable-expose",
-		Summary:  "Avoid documenting EXPOSE with sensitive ports",
-		Details:  "The EXPOSE command is metadata and does not actually open ports. Documenting the intention to expose sensitive ports poses a security concern.",
-		Commands: []commands.DockerCommand{commands.Expose},
-		Evaluator: validations.MultiContextPerNodeEvaluator{
-			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
-				_, defs := docker.Instruction(node)
-				exposeList, err := types.ParseExposeList(defs)
-				if err != nil {
-					logrus.WithError(err).Debugf("Unable to parse list of exposed ports at line %d.", validationContext.Locations[0].Start.Line)
-					return model.Failure
-				}
-
-				questionable := false
-				for _, expose := range exposeList {
-					for _, c := range commonPorts {
-						if expose.PortRange.Intersects(c.PortRange) {
-							logrus.Infof("Found questionable port exposed %s.", portDescriptionFormatted(c))
-							questionable = true
-						}
-					}
+func questionableExpose() validations.Rule {
+	check := exposeCheck(func(ports []exposedPort, settings Settings) map[int][]scriptFinding {
+		findings := make(map[int][]scriptFinding)
+		denied := deniedPorts(settings)
+		allowed := policyPorts("allow", settings.Expose.Allow)
+		for _, port := range ports {
+			// only the ports of the final stage, or of the stages it's built from, are documented by the image
+			if !port.isResolved() || port.Stage == nil || !port.Stage.InFinalImage {
+				continue
+			}
+			for _, policy := range denied {
+				if appliesTo(policy, port.Expose) && port.PortRange.Intersects(policy.PortRange) {
+					findings[port.Line] = append(findings[port.Line], scriptFinding{Location: port.Location, Text: policy.String()})
 				}
+			}
+			if len(allowed) == 0 {
+				continue
+			}
+			isAllowed := false
+			for _, policy := range allowed {
+				isAllowed = isAllowed || (appliesTo(policy, port.Expose) && policy.PortRange.Contains(port.PortRange))
+			}
+			if !isAllowed {
+				findings[port.Line] = append(findings[port.Line], scriptFinding{Location: port.Location, Text: fmt.Sprintf("%s (not allowed)", port.describe())})
+			}
+		}
+		return findings
+	})
 
-				if questionable {
-					return model.Failure
-				}
-				return model.Success
-			},
+	r := validations.MultiContextRule{
+		Name:    "questionable-expose",
+		Summary: "Avoid documenting EXPOSE with sensitive ports",
+		Details: "The EXPOSE command is metadata and does not actually open ports. Documenting the intention to expose sensitive ports poses a security concern. " +
+			"Configure settings.expose.deny to replace the default list of sensitive ports, and settings.expose.allow to restrict exposed ports.",
+		Commands:         []commands.DockerCommand{commands.Expose},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Failure),
 		},
 	}
 	return &r
@@ -6,17 +6,16 @@ import (
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/jimschubert/docked/model/validations"
-	"github.com/moby/buildkit/frontend/dockerfile/instructions"
-	log "github.com/sirupsen/logrus"
 )
 
 func reservedLabels() validations.Rule {
 	r := validations.MultiContextRule{
-		Name:     "reserved-labels",
-		Summary:  "You can't define labels which are reserved by docker.",
-		Details:  "Docker reserves the following namespaces in labels: `com.docker.*`, `io.docker.*`, and `org.dockerproject.*`.",
-		Priority: model.CriticalPriority,
-		Commands: []commands.DockerCommand{commands.Label},
+		Name:             "reserved-labels",
+		Summary:          "You can't define labels which are reserved by docker.",
+		Details:          "Docker reserves the following namespaces in labels: `com.docker.*`, `io.docker.*`, and `org.dockerproject.*`.",
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Label},
+		AppliesToOnbuild: true,
 		Evaluator: validations.MultiContextFullEvaluator{
 			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
 				if mcr == nil || mcr.ContextCache == nil {
@@ -30,13 +29,12 @@ func reservedLabels() validations.Rule {
 				validationContexts := make([]validations.ValidationContext, 0)
 
 				for _, nodeValidationContext := range *mcr.ContextCache {
-					which, err := instructions.ParseCommand(&nodeValidationContext.Node)
-					if err != nil {
-						log.Warnf("unable to parse label: %s", nodeValidationContext.Node.Value)
+					parsed := instructionOf(&nodeValidationContext.Node, nodeValidationContext.Context)
+					if parsed.Err != nil {
 						continue
 					}
 
-					if labelCommand, ok := which.(*instructions.LabelCommand); ok && labelCommand != nil {
+					if labelCommand := parsed.Label(); labelCommand != nil {
 						for _, label := range labelCommand.Labels {
 							if strings.Contains(label.Key, "com.docker.") ||
 								strings.Contains(label.Key, "io.docker.") ||
@@ -9,13 +9,14 @@ import (
 // Concept taken from https://github.com/docker-library/dockerfile-validator
 func layeredChownChmod() validations.Rule {
 	rule := validations.SimpleDeferredRegexRule{
-		Name:     "layered-ownership-change",
-		Summary:  "Change ownership in the same layer as file operation (RUN or COPY)",
-		Details:  "In AUFS, ownership defined in an earlier layer can not be overridden by a broader mask in a later layer.",
-		Patterns: []string{`^ch(own|mod)\b`},
-		Priority: model.MediumPriority,
-		Commands: []commands.DockerCommand{commands.Run},
-		URL:      model.StringPtr("https://github.com/moby/moby/issues/783#issuecomment-19237045"),
+		Name:             "layered-ownership-change",
+		Summary:          "Change ownership in the same layer as file operation (RUN or COPY)",
+		Details:          "In AUFS, ownership defined in an earlier layer can not be overridden by a broader mask in a later layer.",
+		Patterns:         []string{`^ch(own|mod)\b`},
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://github.com/moby/moby/issues/783#issuecomment-19237045"),
 	}
 	return &rule
 }
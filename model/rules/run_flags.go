@@ -0,0 +1,60 @@
+package rules
+
+import (
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+func runSecurityInsecure() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "run-security-insecure",
+		Summary: "Avoid RUN --security=insecure",
+		Details: "RUN --security=insecure runs the command without sandboxing, similar to a privileged container. " +
+			"It requires the security.insecure entitlement and exposes the build host to the command.",
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#run---security"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				if instructionOf(node, validationContext).Flags.Security == instructions.SecurityInsecure {
+					return model.Failure
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func runNetworkHost() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "run-network-host",
+		Summary: "Avoid RUN --network=host",
+		Details: "RUN --network=host gives the command access to the build host's network stack. " +
+			"It requires the network.host entitlement, and builds may behave differently across hosts.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#run---network"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				if instructionOf(node, validationContext).Flags.Network == instructions.NetworkHost {
+					return model.Failure
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(runSecurityInsecure())
+	AddRule(runNetworkHost())
+}
@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"regexp"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+var (
+	secretVariableName    = regexp.MustCompile(`(?i)(^|_)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)($|_)`)
+	secretReferenceSuffix = regexp.MustCompile(`(?i)_(FILE|PATH|DIR|URL|LENGTH)$`)
+)
+
+// isSecretVariableName determines whether name suggests a variable holds a secret value, rather than a reference to one (e.g. PASSWORD_FILE)
+func isSecretVariableName(name string) bool {
+	return secretVariableName.MatchString(name) && !secretReferenceSuffix.MatchString(name)
+}
+
+func secretEnvVariable() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "secret-env-variable",
+		Summary: "Pass secrets with RUN --mount=type=secret rather than ENV or ARG",
+		Details: "Values of ENV and ARG are persisted in image metadata and build history, where they can be read by anyone with the image. " +
+			"Use RUN --mount=type=secret to expose secrets only to the instruction which needs them.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Env, commands.Arg},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/secrets/"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				parsed := instructionOf(node, validationContext)
+				names := make([]string, 0)
+				if env := parsed.Env(); env != nil {
+					for _, kvp := range env.Env {
+						names = append(names, kvp.Key)
+					}
+				}
+				if arg := parsed.Arg(); arg != nil {
+					for _, kvp := range arg.Args {
+						names = append(names, kvp.Key)
+					}
+				}
+
+				for _, name := range names {
+					if isSecretVariableName(name) {
+						return model.Failure
+					}
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(secretEnvVariable())
+}
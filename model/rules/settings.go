@@ -0,0 +1,116 @@
+package rules
+
+import "github.com/jimschubert/docked/model/validations"
+
+// Settings configures the behavior of individual rules. The zero value applies each rule's defaults.
+type Settings struct {
+	// PackagePinning configures the package version pinning rules
+	PackagePinning PackagePinningSettings `yaml:"package_pinning,omitempty"`
+	// NonRootImages lists additional base images known to run as a non-root user, as path.Match patterns
+	// (e.g. registry.example.com/base/*:nonroot)
+	NonRootImages []string `yaml:"non_root_images,omitempty"`
+	// Secrets configures the providers used to detect hard-coded secrets
+	Secrets SecretsSettings `yaml:"secrets,omitempty"`
+	// BaseImages configures the policy for images used by FROM
+	BaseImages BaseImageSettings `yaml:"base_images,omitempty"`
+	// ImageCatalog is the path of a file describing base images, whose entries take precedence over the built-in catalog
+	ImageCatalog string `yaml:"image_catalog,omitempty"`
+	// Labels configures the schema of labels defined by LABEL
+	Labels LabelSettings `yaml:"labels,omitempty"`
+	// Expose configures the policy for ports documented by EXPOSE
+	Expose ExposeSettings `yaml:"expose,omitempty"`
+}
+
+// PackagePinningSettings configures the package version pinning rules
+type PackagePinningSettings struct {
+	// Allow lists packages which may be installed without a version, either by name (e.g. ca-certificates)
+	// or qualified by package manager (e.g. apt-get:ca-certificates)
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// SecretsSettings configures the providers used to detect hard-coded secrets
+type SecretsSettings struct {
+	// Disable lists built-in providers which should not be used, by name (e.g. jwt)
+	Disable []string `yaml:"disable,omitempty"`
+	// Providers are additional providers, e.g. for organization-specific tokens
+	Providers []SecretProviderSettings `yaml:"providers,omitempty"`
+}
+
+// SecretProviderSettings defines a secret provider via configuration
+type SecretProviderSettings struct {
+	// Name identifies the provider
+	Name string `yaml:"name"`
+	// Description is a human-readable name of the secret, defaulting to Name
+	Description string `yaml:"description,omitempty"`
+	// Pattern is a regular expression matching the secret; the first non-empty capturing group, if any, is the secret value
+	Pattern string `yaml:"pattern"`
+	// MinEntropy is the minimum Shannon entropy (bits per character) of a secret value
+	MinEntropy float64 `yaml:"min_entropy,omitempty"`
+}
+
+// BaseImageSettings configures the policy for images used by FROM. Images are matched by patterns compatible with path.Match
+// against the familiar (python) or fully-qualified (docker.io/library/python) name, where a pattern also matches any
+// image beneath it (gcr.io/distroless matches gcr.io/distroless/static).
+type BaseImageSettings struct {
+	// Allow lists the registries or repositories which base images must match, allowing any when empty
+	Allow []string `yaml:"allow,omitempty"`
+	// Deny lists registries or repositories which base images must not match
+	Deny []string `yaml:"deny,omitempty"`
+	// RequireDigest requires the base image of the final stage to be pinned by digest (@sha256:...)
+	RequireDigest bool `yaml:"require_digest,omitempty"`
+	// Deprecated lists deprecated or end-of-life images in addition to the built-in list
+	Deprecated []DeprecatedImage `yaml:"deprecated,omitempty"`
+	// Supported lists images, in the form of DeprecatedImage.Image (e.g. python:3.9), which are excluded from the built-in
+	// list of deprecated images; images listed by Deprecated are always reported
+	Supported []string `yaml:"supported,omitempty"`
+	// RequirePlatform requires FROM --platform for every stage
+	RequirePlatform bool `yaml:"require_platform,omitempty"`
+	// Platforms lists the allowed values of FROM --platform (e.g. linux/amd64); variables such as $BUILDPLATFORM are always allowed
+	Platforms []string `yaml:"platforms,omitempty"`
+}
+
+// DeprecatedImage is a deprecated or end-of-life base image, along with its replacement
+type DeprecatedImage struct {
+	// Image is the repository pattern and an optional tag, which may be a glob (centos:*), a version prefix (python:2 matches
+	// python:2.7-slim), or a version bound (node:<16)
+	Image string `yaml:"image"`
+	// Replacement suggests an image to use instead
+	Replacement string `yaml:"replacement,omitempty"`
+}
+
+// LabelSettings configures the schema of labels, e.g. for an organization's namespace
+type LabelSettings struct {
+	// Required lists label keys which the final image must define (e.g. org.opencontainers.image.source)
+	Required []string `yaml:"required,omitempty"`
+	// Patterns are regular expressions which label values must match, by key. Keys are path.Match patterns,
+	// where com.example.* matches every label in the com.example namespace.
+	Patterns map[string]string `yaml:"patterns,omitempty"`
+}
+
+// ExposeSettings configures the policy for ports documented by EXPOSE in the final image. Ports are written as in EXPOSE,
+// a port (22) or range (8000-8099) with an optional protocol (53/udp), where ports without a protocol match any protocol.
+type ExposeSettings struct {
+	// Deny lists ports which must not be exposed, replacing the built-in list of sensitive ports (e.g. 22 for SSH) when defined.
+	// An empty list denies no ports.
+	Deny []string `yaml:"deny,omitempty"`
+	// Allow lists the ports which exposed ports must be within, allowing any when empty
+	Allow []string `yaml:"allow,omitempty"`
+	// Required lists ports or ranges of which the final image must expose at least one port each (e.g. 8000-8099 for a service port)
+	Required []string `yaml:"required,omitempty"`
+}
+
+// settingsOf returns the Settings configured for the analysis of validationContext, or the zero value when none are configured
+func settingsOf(validationContext validations.ValidationContext) Settings {
+	if s, ok := validationContext.Settings.(*Settings); ok && s != nil {
+		return *s
+	}
+	return Settings{}
+}
+
+// ruleSettings returns the Settings configured for the analysis evaluated by mcr, which is shared by each cached context
+func ruleSettings(mcr *validations.MultiContextRule) Settings {
+	if mcr == nil || mcr.ContextCache == nil || len(*mcr.ContextCache) == 0 {
+		return Settings{}
+	}
+	return settingsOf((*mcr.ContextCache)[0].Context)
+}
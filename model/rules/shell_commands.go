@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// runCommands parses the commands of a RUN instruction using the shell active in its build stage.
+// Parse failures are logged here, callers only need to skip evaluation.
+func runCommands(node *parser.Node, validationContext validations.ValidationContext) ([]shell.PosixCommand, error) {
+	posixCommands, err := shell.NewPosixCommandFromNodeWithShell(node, validationContext.Shell)
+	if err != nil {
+		var unsupported *shell.UnsupportedShellError
+		if errors.As(err, &unsupported) {
+			log.Debugf("Skipping validation of RUN command at %v: %s", validationContext.Locations, err)
+		} else {
+			log.Warnf("Unable to parse RUN command, skipping validation: %#v", node.Location())
+		}
+	}
+	return posixCommands, err
+}
+
+// unevaluatedReason explains why a RUN command could not be evaluated
+func unevaluatedReason(err error) string {
+	return fmt.Sprintf("Unable to evaluate RUN command: %s", err)
+}
+
+// unevaluatedRun explains why the commands of a RUN instruction can't be evaluated, or returns an empty string when they can.
+// This is the MultiContextPerNodeEvaluator.Unevaluated of rules evaluating RUN via runCommands.
+func unevaluatedRun(node *parser.Node, validationContext validations.ValidationContext) string {
+	if _, err := shell.NewPosixCommandFromNodeWithShell(node, validationContext.Shell); err != nil {
+		return unevaluatedReason(err)
+	}
+	return ""
+}
+
+// unevaluatedRuns collects the RUN instructions which couldn't be evaluated, such as those run by an unsupported SHELL,
+// so that a rule finding no problems reports model.Skipped along with the reasons rather than model.Success
+type unevaluatedRuns struct {
+	reasons  []string
+	contexts []validations.ValidationContext
+}
+
+// add records that the instruction of validationContext couldn't be evaluated due to err
+func (u *unevaluatedRuns) add(validationContext validations.ValidationContext, err error) {
+	if reason := unevaluatedReason(err); !model.StringSliceContains(&u.reasons, reason) {
+		u.reasons = append(u.reasons, reason)
+	}
+	u.contexts = append(u.contexts, validationContext)
+}
+
+// result returns result, unless it's a success or skipped while instructions couldn't be evaluated,
+// in which case the result is model.Skipped with the reasons
+func (u *unevaluatedRuns) result(result *validations.ValidationResult) *validations.ValidationResult {
+	if len(u.reasons) == 0 || result == nil || (result.Result != model.Success && result.Result != model.Skipped) {
+		return result
+	}
+	return &validations.ValidationResult{
+		Result:   model.Skipped,
+		Details:  strings.Join(u.reasons, "; "),
+		Contexts: u.contexts,
+	}
+}
+
+// locatedCommand is a shell command along with the locations of its name and arguments within the Dockerfile
+type locatedCommand struct {
+	shell.PosixCommand
+	// NameLocation is the location of the command name, or a zero Location if not found
+	NameLocation docker.Location
+	// ArgLocations holds the location of each argument in Args, or a zero Location if not found
+	ArgLocations []docker.Location
+}
+
+// locateCommands finds the locations of each command's name and arguments within the instruction's source text.
+// Commands are expected in the order they appear in the instruction. Words which can't be found, such as quoted or
+// expanded arguments, are given a zero Location.
+func locateCommands(source *docker.Source, posixCommands []shell.PosixCommand) []locatedCommand {
+	located := make([]locatedCommand, 0, len(posixCommands))
+	cursor := docker.Position{}
+	find := func(text string) docker.Location {
+		if location, ok := source.Find(text, cursor); ok {
+			cursor = location.End
+			return location
+		}
+		return docker.Location{}
+	}
+	for _, command := range posixCommands {
+		current := locatedCommand{PosixCommand: command, ArgLocations: make([]docker.Location, len(command.Args))}
+		current.NameLocation = find(command.Name)
+		for i, arg := range command.Args {
+			current.ArgLocations[i] = find(arg)
+		}
+		located = append(located, current)
+	}
+	return located
+}
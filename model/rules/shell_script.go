@@ -0,0 +1,373 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	log "github.com/sirupsen/logrus"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// errexitFlags matches option arguments of set and sh which enable errexit, e.g. -e or -eux
+var errexitFlags = regexp.MustCompile(`^-[a-zA-Z]*e[a-zA-Z]*$`)
+
+// runScript parses the shell form of a RUN instruction, returning a nil script for exec form and an error for scripts
+// which can't be parsed
+func runScript(node *parser.Node, validationContext validations.ValidationContext) (*shell.Script, error) {
+	if node.Attributes["json"] {
+		return nil, nil
+	}
+	script, err := shell.NewScript(node, validationContext.Source, validationContext.Shell)
+	if err != nil {
+		var unsupported *shell.UnsupportedShellError
+		if !errors.As(err, &unsupported) {
+			log.Debugf("Unable to parse RUN script at %v: %s", validationContext.Locations, err)
+		}
+		return nil, err
+	}
+	return script, nil
+}
+
+// callName is the literal name of the command called by stmt, or empty if stmt isn't a simple command
+func callName(stmt *syntax.Stmt) string {
+	if stmt == nil {
+		return ""
+	}
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return ""
+	}
+	return strings.TrimLeft(call.Args[0].Lit(), `\`)
+}
+
+// enablesErrexit determines whether arguments of set or sh (e.g. -eux, -o errexit) enable errexit
+func enablesErrexit(args []string) bool {
+	for i, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if errexitFlags.MatchString(arg) || (arg == "-o" && i+1 < len(args) && args[i+1] == "errexit") {
+			return true
+		}
+	}
+	return false
+}
+
+// errexitFrom finds the index of the top-level statement of script which enables errexit (set -e). Returns -1 where
+// the SHELL instruction enables errexit for the whole script, or len(script.File.Stmts) if errexit is never enabled.
+func errexitFrom(script *shell.Script, shellArgs []string) int {
+	if len(shellArgs) > 1 && enablesErrexit(shellArgs[1:]) {
+		return -1
+	}
+	for i, stmt := range script.File.Stmts {
+		if callName(stmt) != "set" {
+			continue
+		}
+		args := make([]string, 0)
+		for _, word := range stmt.Cmd.(*syntax.CallExpr).Args[1:] {
+			args = append(args, word.Lit())
+		}
+		if enablesErrexit(args) {
+			return i
+		}
+	}
+	return len(script.File.Stmts)
+}
+
+// printNode formats node as shell source, e.g. to describe a finding
+func printNode(node syntax.Node) string {
+	buf := bytes.Buffer{}
+	if err := syntax.NewPrinter(syntax.SingleLine(true)).Print(&buf, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// scriptFinding is a problem found within a RUN script
+type scriptFinding struct {
+	Location docker.Location
+	Text     string
+}
+
+// scriptCheck finds problems within the script of a RUN instruction
+type scriptCheck func(script *shell.Script, validationContext validations.ValidationContext) []scriptFinding
+
+// evaluate applies the check to the script of each shell-form RUN instruction, resulting in result for any findings
+func (check scriptCheck) evaluate(result model.Valid) func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+	return func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+		unevaluated := unevaluatedRuns{}
+		return unevaluated.result(runFindings(mcr, result, func(nodeContext validations.NodeValidationContext) []scriptFinding {
+			script, err := runScript(&nodeContext.Node, nodeContext.Context)
+			if err != nil {
+				unevaluated.add(nodeContext.Context, err)
+				return nil
+			}
+			if script == nil {
+				return nil
+			}
+			return check(script, nodeContext.Context)
+		}))
+	}
+}
+
+// runFindings evaluates each RUN instruction with find, resulting in result for any findings. Findings without a
+// location are reported at the location of their instruction.
+func runFindings(mcr *validations.MultiContextRule, result model.Valid, find func(nodeContext validations.NodeValidationContext) []scriptFinding) *validations.ValidationResult {
+	if mcr == nil || mcr.ContextCache == nil {
+		return validations.NewValidationResultSkipped(mcr.GetSummary())
+	}
+
+	found := make([]string, 0)
+	validationContexts := make([]validations.ValidationContext, 0)
+	for _, nodeContext := range *mcr.ContextCache {
+		for _, finding := range find(nodeContext) {
+			found = append(found, finding.Text)
+			validationContext := nodeContext.Context
+			validationContext.CausedFailure = result == model.Failure
+			validationContext.HasRecommendations = result == model.Recommendation
+			if finding.Location != (docker.Location{}) {
+				validationContext.Locations = []docker.Location{finding.Location}
+			}
+			validationContexts = append(validationContexts, validationContext)
+		}
+	}
+
+	if len(found) == 0 {
+		return &validations.ValidationResult{
+			Result:  model.Success,
+			Details: mcr.GetSummary(),
+		}
+	}
+	return &validations.ValidationResult{
+		Result:   result,
+		Details:  fmt.Sprintf("%s Found: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+		Contexts: validationContexts,
+	}
+}
+
+func unquotedVariable() validations.Rule {
+	check := scriptCheck(func(script *shell.Script, _ validations.ValidationContext) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		syntax.Walk(script.File, func(node syntax.Node) bool {
+			call, ok := node.(*syntax.CallExpr)
+			if !ok {
+				return true
+			}
+			for _, word := range call.Args {
+				for _, part := range word.Parts {
+					param, ok := part.(*syntax.ParamExp)
+					if !ok || param.Length || param.Param == nil || strings.ContainsAny(param.Param.Value, "?#$!-") {
+						continue
+					}
+					name := "$" + param.Param.Value
+					if !param.Short {
+						name = "${" + param.Param.Value + "}"
+					}
+					findings = append(findings, scriptFinding{Location: script.Location(param), Text: name})
+				}
+			}
+			return true
+		})
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "unquoted-variable",
+		Summary: "Double quote variables in RUN to prevent word splitting",
+		Details: "Unquoted variables are split on whitespace and expanded as globs, so values containing spaces or wildcards become several arguments. " +
+			`Wrap the variable in double quotes, e.g. "$VERSION".`,
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://www.shellcheck.net/wiki/SC2086"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+// unguardedCd finds cd commands within stmt whose failure doesn't affect the status of stmt, excluding those guarded by ||
+func unguardedCd(stmt *syntax.Stmt) []*syntax.CallExpr {
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		if callName(stmt) == "cd" {
+			return []*syntax.CallExpr{cmd}
+		}
+	case *syntax.BinaryCmd:
+		switch cmd.Op {
+		case syntax.AndStmt:
+			return append(unguardedCd(cmd.X), unguardedCd(cmd.Y)...)
+		case syntax.OrStmt:
+			return unguardedCd(cmd.Y)
+		}
+	}
+	return nil
+}
+
+func cdWithoutGuard() validations.Rule {
+	check := scriptCheck(func(script *shell.Script, validationContext validations.ValidationContext) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		errexit := errexitFrom(script, validationContext.Shell)
+		syntax.Walk(script.File, func(node syntax.Node) bool {
+			var stmts []*syntax.Stmt
+			switch t := node.(type) {
+			case *syntax.File:
+				stmts = t.Stmts
+			case *syntax.Block:
+				stmts = t.Stmts
+			case *syntax.Subshell:
+				stmts = t.Stmts
+			default:
+				return true
+			}
+			// a failure of the last statement fails the list, so only earlier statements are evaluated
+			for i := 0; i < len(stmts)-1; i++ {
+				if _, ok := stmts[i].Cmd.(*syntax.CallExpr); ok && (errexit < 0 || (errexit < len(script.File.Stmts) && stmts[i].Pos().After(script.File.Stmts[errexit].End()))) {
+					continue
+				}
+				for _, cd := range unguardedCd(stmts[i]) {
+					findings = append(findings, scriptFinding{Location: script.Location(cd.Args[0]), Text: printNode(cd)})
+				}
+			}
+			return true
+		})
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "cd-without-guard",
+		Summary: "Exit when cd fails within RUN",
+		Details: "If cd fails, the commands which follow run in the wrong directory. Use cd dir || exit 1, chain the following commands with &&, or enable set -e. " +
+			"Alternatively, use WORKDIR.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://www.shellcheck.net/wiki/SC2164"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func missingSetE() validations.Rule {
+	check := scriptCheck(func(script *shell.Script, validationContext validations.ValidationContext) []scriptFinding {
+		stmts := script.File.Stmts
+		errexit := errexitFrom(script, validationContext.Shell)
+		for i := 1; i < len(stmts); i++ {
+			// statements separated by ; are evaluated by semicolon-chain
+			if stmts[i-1].Semicolon.IsValid() {
+				continue
+			}
+			if errexit >= i {
+				return []scriptFinding{{Location: script.Location(stmts[0]), Text: fmt.Sprintf("%d commands", len(stmts))}}
+			}
+			break
+		}
+		return nil
+	})
+	r := validations.MultiContextRule{
+		Name:    "missing-set-e",
+		Summary: "Enable set -e for RUN scripts of multiple lines",
+		Details: "The status of a RUN script is that of its last command, so failures of earlier commands on separate lines are ignored and the build continues. " +
+			"Begin the script with set -e (or set -eux), or run it with a SHELL which enables errexit.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://www.gnu.org/software/bash/manual/html_node/The-Set-Builtin.html"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func semicolonChain() validations.Rule {
+	check := scriptCheck(func(script *shell.Script, validationContext validations.ValidationContext) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		stmts := script.File.Stmts
+		errexit := errexitFrom(script, validationContext.Shell)
+		for i := 0; i < len(stmts)-1; i++ {
+			stmt := stmts[i]
+			if !stmt.Semicolon.IsValid() || stmt.Background || stmt.Coprocess || stmt.Disown || errexit <= i {
+				continue
+			}
+			// failures handled explicitly, e.g. rm -f x || true;
+			if binary, ok := stmt.Cmd.(*syntax.BinaryCmd); ok && binary.Op == syntax.OrStmt {
+				continue
+			}
+			start := script.Position(stmt.Semicolon)
+			findings = append(findings, scriptFinding{
+				Location: docker.Location{Start: start, End: docker.Position{Line: start.Line, Character: start.Character + 1}},
+				Text:     printNode(stmt.Cmd) + ";",
+			})
+		}
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:    "semicolon-chain",
+		Summary: "Chain commands in RUN with && rather than ;",
+		Details: "Commands separated by ; run regardless of whether the previous command failed, so a failed step may be hidden by a later successful one. " +
+			"Use && so the instruction stops at the first failure, or enable set -e.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/build/building/best-practices/#run"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func uselessCat() validations.Rule {
+	check := scriptCheck(func(script *shell.Script, _ validations.ValidationContext) []scriptFinding {
+		findings := make([]scriptFinding, 0)
+		syntax.Walk(script.File, func(node syntax.Node) bool {
+			binary, ok := node.(*syntax.BinaryCmd)
+			if !ok || binary.Op != syntax.Pipe || callName(binary.X) != "cat" || len(binary.X.Redirs) > 0 {
+				return true
+			}
+			args := binary.X.Cmd.(*syntax.CallExpr).Args[1:]
+			if len(args) == 1 && !strings.HasPrefix(args[0].Lit(), "-") {
+				findings = append(findings, scriptFinding{Location: script.Location(binary.X), Text: printNode(binary.X)})
+			}
+			return true
+		})
+		return findings
+	})
+	r := validations.MultiContextRule{
+		Name:             "useless-cat",
+		Summary:          "Avoid piping a single file from cat",
+		Details:          "Piping one file from cat starts an extra process. Pass the file to the command as an argument, or redirect it with < file.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://www.shellcheck.net/wiki/SC2002"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: check.evaluate(model.Recommendation),
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(unquotedVariable())
+	AddRule(cdWithoutGuard())
+	AddRule(missingSetE())
+	AddRule(semicolonChain())
+	AddRule(uselessCat())
+}
@@ -8,12 +8,13 @@ import (
 
 func singleCmd() validations.Rule {
 	r := validations.MultiContextRule{
-		Name:     "single-cmd",
-		Summary:  "Only a single CMD instruction is supported",
-		Details:  "More than one CMD may indicate a programming error. Docker will run the last CMD instruction only, but this could be a security concern.",
-		Priority: model.CriticalPriority,
-		Commands: []commands.DockerCommand{commands.Cmd},
-		URL:      model.StringPtr("https://docs.docker.com/engine/reference/builder/#cmd"),
+		Name:             "single-cmd",
+		Summary:          "Only a single CMD instruction is supported",
+		Details:          "More than one CMD within a build stage may indicate a programming error. Docker will run the last CMD instruction only, but this could be a security concern.",
+		Priority:         model.CriticalPriority,
+		Commands:         []commands.DockerCommand{commands.Cmd},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/engine/reference/builder/#cmd"),
 		Evaluator: validations.MultiContextFullEvaluator{
 			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
 				if mcr == nil || mcr.ContextCache == nil {
@@ -25,9 +26,19 @@ func singleCmd() validations.Rule {
 
 				result := model.Success
 				validationContexts := make([]validations.ValidationContext, 0)
-				for _, nodeContext := range *mcr.ContextCache {
-					result = model.Failure
-					validationContexts = append(validationContexts, nodeContext.Context)
+				for _, stage := range stagesOf(*mcr.ContextCache) {
+					stageContexts := make([]validations.ValidationContext, 0)
+					for _, nodeContext := range stage {
+						stageContexts = append(stageContexts, nodeContext.Context)
+					}
+					if len(stageContexts) > 1 {
+						result = model.Failure
+						// all but the last are overridden
+						for i := 0; i < len(stageContexts)-1; i++ {
+							stageContexts[i].CausedFailure = true
+						}
+					}
+					validationContexts = append(validationContexts, stageContexts...)
 				}
 
 				return &validations.ValidationResult{
@@ -0,0 +1,272 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/shell"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// valueFlags are the short flags taking a value, by command, which must be skipped when finding operands
+var valueFlags = map[string]string{
+	"cp":      "tS",
+	"mv":      "tS",
+	"ln":      "tS",
+	"install": "tSmog",
+	"rsync":   "ef",
+	"sed":     "ef",
+}
+
+// operands finds the indexes of args which aren't flags or the values of flags, where short flags in shortValues take a value
+func operands(args []string, shortValues string) []int {
+	indexes := make([]int, 0)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for j := i + 1; j < len(args); j++ {
+				indexes = append(indexes, j)
+			}
+			return indexes
+		case strings.HasPrefix(arg, "--"):
+			// long flags are expected to attach their values (--target-directory=/data)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// the value of the last short flag is attached or the next argument (-m 0755, -m0755)
+			if idx := strings.IndexAny(arg[1:], shortValues); shortValues != "" && idx == len(arg)-2 {
+				i++
+			}
+		default:
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// flagValue finds the value of a flag given as a short flag (-C /opt, -C/opt) or long flag (--directory=/opt, --directory /opt),
+// returning the index of the argument holding the value
+func flagValue(args []string, short string, long string) (int, string, bool) {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return 0, "", false
+		case long != "" && arg == long && i+1 < len(args):
+			return i + 1, args[i+1], true
+		case long != "" && strings.HasPrefix(arg, long+"="):
+			return i, strings.TrimPrefix(arg, long+"="), true
+		case short != "" && !strings.HasPrefix(arg, "--") && strings.HasPrefix(arg, "-") && strings.Contains(arg, short):
+			idx := strings.Index(arg, short)
+			if value := arg[idx+len(short):]; value != "" {
+				return i, value, true
+			}
+			if i+1 < len(args) {
+				return i + 1, args[i+1], true
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// writtenPath is an argument of a command which the command creates or modifies
+type writtenPath struct {
+	// Index of the argument holding the path
+	Index int
+	Path  string
+}
+
+// writtenPaths finds the paths created or modified by command. Arguments which are only read, such as the source of cp, are excluded.
+func writtenPaths(command *shell.PosixCommand) []writtenPath {
+	name := path.Base(command.Name)
+	args := command.Args
+	written := make([]writtenPath, 0)
+	addOperands := func(indexes []int) {
+		for _, i := range indexes {
+			written = append(written, writtenPath{Index: i, Path: args[i]})
+		}
+	}
+	addFlag := func(short string, long string) {
+		if i, value, ok := flagValue(args, short, long); ok {
+			written = append(written, writtenPath{Index: i, Path: value})
+		}
+	}
+
+	switch name {
+	case "mkdir", "touch", "rm":
+		addOperands(operands(args, ""))
+	case "chown", "chmod", "chgrp":
+		// the first operand is the owner or mode
+		if indexes := operands(args, valueFlags[name]); len(indexes) > 1 {
+			addOperands(indexes[1:])
+		}
+	case "sed":
+		if _, _, inPlace := flagValue(args, "i", "--in-place"); inPlace {
+			addOperands(operands(args, valueFlags[name]))
+		}
+	case "cp", "mv", "ln", "install", "rsync":
+		if _, _, ok := flagValue(args, "t", "--target-directory"); ok {
+			addFlag("t", "--target-directory")
+			break
+		}
+		indexes := operands(args, valueFlags[name])
+		switch {
+		case name == "install" && model.StringSliceContains(&args, "-d"):
+			// install -d creates each operand as a directory
+			addOperands(indexes)
+		case len(indexes) > 1:
+			addOperands(indexes[len(indexes)-1:])
+		}
+	case "tar":
+		switch {
+		case !isArchiveCreate(args):
+			addFlag("C", "--directory")
+		case len(args) > 1 && !strings.HasPrefix(args[0], "-") && strings.Contains(args[0], "f"):
+			// the traditional style (tar cf archive.tar dir) gives the archive as the next argument
+			written = append(written, writtenPath{Index: 1, Path: args[1]})
+		default:
+			addFlag("f", "--file")
+		}
+	case "unzip":
+		addFlag("d", "")
+	case "curl":
+		addFlag("o", "--output")
+	case "wget":
+		addFlag("O", "--output-document")
+		addFlag("P", "--directory-prefix")
+	case "git":
+		// git clone <repository> [<directory>]
+		if indexes := operands(args, "cbo"); len(indexes) == 3 && args[indexes[0]] == "clone" {
+			addOperands(indexes[2:])
+		}
+	}
+	return written
+}
+
+// isArchiveCreate determines whether tar args create an archive (-c, --create) rather than extract or list one
+func isArchiveCreate(args []string) bool {
+	for i, arg := range args {
+		switch {
+		case arg == "--create":
+			return true
+		case i == 0 && !strings.HasPrefix(arg, "-") && strings.Contains(arg, "c"):
+			return true
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c"):
+			return true
+		}
+	}
+	return false
+}
+
+func volumeInBuilder() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "volume-in-builder",
+		Summary: "Avoid VOLUME in builder stages",
+		Details: "A VOLUME in a builder stage doesn't carry over to the final image, but discards changes made to the path by later RUN instructions of the stage. " +
+			"Declare volumes in the final stage only.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Volume},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#volume"),
+		Evaluator: validations.MultiContextPerNodeEvaluator{
+			Fn: func(node *parser.Node, validationContext validations.ValidationContext) model.Valid {
+				if validationContext.IsBuilderContext {
+					return model.Recommendation
+				}
+				return model.Success
+			},
+		},
+	}
+	return &r
+}
+
+func volumeDiscardsWrites() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "volume-discards-writes",
+		Summary: "RUN instructions after VOLUME should not modify the volume",
+		Details: "Once a path is declared as a VOLUME, changes made to it by later RUN instructions are discarded. " +
+			"Create, populate, and set the ownership of the path before declaring the VOLUME.",
+		Priority:         model.HighPriority,
+		Commands:         []commands.DockerCommand{commands.Volume, commands.Run, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#notes-about-specifying-volumes"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// builder stages are tracked only so a stage built FROM a named stage inherits its volumes
+				stages := make(map[string][]string)
+				var stageName string
+				volumes := make([]string, 0)
+				found := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				unevaluated := unevaluatedRuns{}
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					if stage := parsed.From(); stage != nil {
+						volumes = append([]string{}, stages[strings.ToLower(stage.BaseName)]...)
+						stageName = stage.Name
+					} else if volume := parsed.Volume(); volume != nil {
+						for _, v := range volume.Volumes {
+							if path.IsAbs(v) {
+								volumes = append(volumes, path.Clean(v))
+							}
+						}
+					} else if len(volumes) > 0 && parsed.Run() != nil {
+						posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+						if err != nil {
+							unevaluated.add(nodeContext.Context, err)
+							continue
+						}
+						for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+							for _, written := range writtenPaths(&command.PosixCommand) {
+								if !path.IsAbs(written.Path) {
+									continue
+								}
+								for _, volume := range volumes {
+									if !isWithin(path.Clean(written.Path), volume) {
+										continue
+									}
+									found = append(found, fmt.Sprintf("%s %s (VOLUME %s)", command.Name, written.Path, volume))
+									validationContext := nodeContext.Context
+									validationContext.CausedFailure = true
+									if command.ArgLocations[written.Index] != (docker.Location{}) {
+										validationContext.Locations = []docker.Location{command.ArgLocations[written.Index]}
+									}
+									validationContexts = append(validationContexts, validationContext)
+									break
+								}
+							}
+						}
+					}
+					if stageName != "" {
+						stages[stageName] = volumes
+					}
+				}
+
+				if len(found) == 0 {
+					return unevaluated.result(&validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					})
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  fmt.Sprintf("%s Discarded: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(volumeInBuilder())
+	AddRule(volumeDiscardsWrites())
+}
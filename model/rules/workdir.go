@@ -0,0 +1,242 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	"github.com/jimschubert/docked/model/validations"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+// systemDirectories hold files installed for the system rather than the application, which are expected outside WORKDIR
+var systemDirectories = []string{"/bin", "/sbin", "/lib", "/lib64", "/usr", "/etc", "/var", "/tmp", "/run", "/root", "/home"}
+
+// isWithin determines whether the absolute path p is dir or is contained by dir
+func isWithin(p string, dir string) bool {
+	return dir == "/" || p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// isVariablePath determines whether p starts with a variable, which can't be resolved without the build arguments
+func isVariablePath(p string) bool {
+	return strings.HasPrefix(p, "$")
+}
+
+func relativeWorkdir() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "relative-workdir",
+		Summary: "Use an absolute path for the first WORKDIR of a stage",
+		Details: "A relative WORKDIR is resolved against the working directory of the base image, which may change between versions. " +
+			"Set an absolute WORKDIR first; later relative paths are then predictable.",
+		Priority:         model.MediumPriority,
+		Commands:         []commands.DockerCommand{commands.Workdir, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#workdir"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				// stages with an absolute WORKDIR, by name, which stages built FROM them inherit
+				absoluteStages := make(map[string]bool)
+				var stageName string
+				var absolute bool
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, nodeContext := range *mcr.ContextCache {
+					parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+					if stage := parsed.From(); stage != nil {
+						absolute = absoluteStages[strings.ToLower(stage.BaseName)]
+						stageName = stage.Name
+						continue
+					}
+					workdir := parsed.Workdir()
+					if workdir == nil || isVariablePath(workdir.Path) {
+						continue
+					}
+					if path.IsAbs(workdir.Path) {
+						absolute = true
+					} else if !absolute {
+						validationContext := nodeContext.Context
+						validationContext.CausedFailure = true
+						validationContexts = append(validationContexts, validationContext)
+					}
+					if stageName != "" {
+						absoluteStages[stageName] = absolute
+					}
+				}
+
+				if len(validationContexts) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Failure,
+					Details:  mcr.GetSummary(),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func runCd() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "run-cd",
+		Summary: "Use WORKDIR instead of cd within RUN",
+		Details: "Changing directories with cd only applies to the current RUN instruction, which is difficult to read, troubleshoot, and maintain. " +
+			"Use WORKDIR to set the directory for subsequent instructions.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Run},
+		AppliesToBuilder: true,
+		AppliesToOnbuild: true,
+		URL:              model.StringPtr("https://docs.docker.com/develop/develop-images/dockerfile_best-practices/#workdir"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				validationContexts := make([]validations.ValidationContext, 0)
+				unevaluated := unevaluatedRuns{}
+				for _, nodeContext := range *mcr.ContextCache {
+					posixCommands, err := runCommands(&nodeContext.Node, nodeContext.Context)
+					if err != nil {
+						unevaluated.add(nodeContext.Context, err)
+						continue
+					}
+					for _, command := range locateCommands(nodeContext.Context.Source, posixCommands) {
+						if command.Name != "cd" {
+							continue
+						}
+						validationContext := nodeContext.Context
+						validationContext.HasRecommendations = true
+						if command.NameLocation != (docker.Location{}) {
+							validationContext.Locations = []docker.Location{command.NameLocation}
+						}
+						validationContexts = append(validationContexts, validationContext)
+					}
+				}
+
+				if len(validationContexts) == 0 {
+					return unevaluated.result(&validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					})
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  mcr.GetSummary(),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+// copiesDirectoryTo determines whether sources are copied into destination as a directory, rather than as a single file
+func copiesDirectoryTo(sources []string, destination string) bool {
+	if len(sources) != 1 || strings.HasSuffix(destination, "/") {
+		return true
+	}
+	source := sources[0]
+	return source == "." || strings.HasSuffix(source, "/") || strings.ContainsAny(source, "*?[")
+}
+
+func copyDestinationWorkdir() validations.Rule {
+	r := validations.MultiContextRule{
+		Name:    "copy-destination-workdir",
+		Summary: "Absolute COPY and ADD destinations should be within the stage's WORKDIR",
+		Details: "Copying application files to an absolute path outside of WORKDIR splits the application across directories, " +
+			"which is often a mistake after WORKDIR changes. Use a destination relative to WORKDIR. System directories such as /usr and /etc are allowed.",
+		Priority:         model.LowPriority,
+		Commands:         []commands.DockerCommand{commands.Copy, commands.Add, commands.Workdir, commands.From},
+		AppliesToBuilder: true,
+		URL:              model.StringPtr("https://docs.docker.com/reference/dockerfile/#workdir"),
+		Evaluator: validations.MultiContextFullEvaluator{
+			Fn: func(mcr *validations.MultiContextRule) *validations.ValidationResult {
+				if mcr == nil || mcr.ContextCache == nil {
+					return validations.NewValidationResultSkipped(mcr.GetSummary())
+				}
+
+				found := make([]string, 0)
+				validationContexts := make([]validations.ValidationContext, 0)
+				for _, stage := range stagesOf(*mcr.ContextCache) {
+					// only a WORKDIR set within the stage is known
+					workdir := ""
+					for _, nodeContext := range stage {
+						parsed := instructionOf(&nodeContext.Node, nodeContext.Context)
+						if w := parsed.Workdir(); w != nil {
+							if isVariablePath(w.Path) || (workdir == "" && !path.IsAbs(w.Path)) {
+								workdir = ""
+							} else {
+								workdir = resolvePath(workdir, w.Path)
+							}
+							continue
+						}
+						var sourcesAndDest *instructions.SourcesAndDest
+						if copyCommand := parsed.Copy(); copyCommand != nil {
+							sourcesAndDest = &copyCommand.SourcesAndDest
+						} else if addCommand := parsed.Add(); addCommand != nil {
+							sourcesAndDest = &addCommand.SourcesAndDest
+						}
+						if sourcesAndDest == nil || workdir == "" || workdir == "/" || !path.IsAbs(sourcesAndDest.DestPath) {
+							continue
+						}
+
+						destination := path.Clean(sourcesAndDest.DestPath)
+						if !copiesDirectoryTo(sourcesAndDest.SourcePaths, sourcesAndDest.DestPath) {
+							// a single file is placed within the destination's directory
+							destination = path.Dir(destination)
+						}
+						if destination == "/" || isWithin(destination, workdir) {
+							continue
+						}
+						system := false
+						for _, directory := range systemDirectories {
+							system = system || isWithin(destination, directory)
+						}
+						if system {
+							continue
+						}
+
+						found = append(found, fmt.Sprintf("%s (WORKDIR %s)", sourcesAndDest.DestPath, workdir))
+						validationContext := nodeContext.Context
+						validationContext.HasRecommendations = true
+						// the destination is the last argument, and may also appear as a source
+						for location, ok := nodeContext.Context.Source.Find(sourcesAndDest.DestPath, docker.Position{}); ok; location, ok = nodeContext.Context.Source.Find(sourcesAndDest.DestPath, location.End) {
+							validationContext.Locations = []docker.Location{location}
+						}
+						validationContexts = append(validationContexts, validationContext)
+					}
+				}
+
+				if len(found) == 0 {
+					return &validations.ValidationResult{
+						Result:  model.Success,
+						Details: mcr.GetSummary(),
+					}
+				}
+				return &validations.ValidationResult{
+					Result:   model.Recommendation,
+					Details:  fmt.Sprintf("%s Outside WORKDIR: %s", mcr.GetSummary(), strings.Join(found, ", ")),
+					Contexts: validationContexts,
+				}
+			},
+		},
+	}
+	return &r
+}
+
+func init() {
+	AddRule(relativeWorkdir())
+	AddRule(runCd())
+	AddRule(copyDestinationWorkdir())
+}
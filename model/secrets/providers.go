@@ -0,0 +1,104 @@
+package secrets
+
+import "regexp"
+
+// catalog holds the built-in providers. Patterns capture the secret value, excluding any surrounding key or prefix
+// which isn't sensitive. Values containing $ are expected to be variable references and are not matched.
+var catalog = []Provider{
+	{
+		Name:        "aws-access-key",
+		Description: "AWS access key ID",
+		Pattern:     regexp.MustCompile(`\b((?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16})\b`),
+	},
+	{
+		Name:        "aws-secret-access-key",
+		Description: "AWS secret access key",
+		Pattern:     regexp.MustCompile(`(?i)(?:aws|secret_access).{0,30}?[=:\s"']([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+=]|$)`),
+		MinEntropy:  4,
+	},
+	{
+		Name:        "github-token",
+		Description: "GitHub token",
+		Pattern:     regexp.MustCompile(`\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`),
+		MinEntropy:  3,
+	},
+	{
+		Name:        "gitlab-token",
+		Description: "GitLab token",
+		Pattern:     regexp.MustCompile(`\b(gl(?:pat|dt|rt|cbt|ptt)-[A-Za-z0-9_\-]{20,})`),
+		MinEntropy:  3,
+	},
+	{
+		Name:        "slack-token",
+		Description: "Slack token",
+		Pattern:     regexp.MustCompile(`\b(xox[abposr]-[A-Za-z0-9-]{10,})`),
+		MinEntropy:  3,
+	},
+	{
+		Name:        "slack-webhook",
+		Description: "Slack webhook URL",
+		Pattern:     regexp.MustCompile(`https://hooks\.slack\.com/services/(T[A-Za-z0-9_]+/B[A-Za-z0-9_]+/[A-Za-z0-9_]{16,})`),
+	},
+	{
+		Name:        "gcp-api-key",
+		Description: "Google Cloud API key",
+		Pattern:     regexp.MustCompile(`\b(AIza[0-9A-Za-z_\-]{35})`),
+		MinEntropy:  3,
+	},
+	{
+		Name:        "gcp-service-account-key",
+		Description: "Google Cloud service account private key ID",
+		Pattern:     regexp.MustCompile(`"private_key_id"\s*:\s*\\?"([a-f0-9]{40})`),
+		MinEntropy:  3,
+	},
+	{
+		Name:        "azure-storage-key",
+		Description: "Azure storage account key",
+		Pattern:     regexp.MustCompile(`(?i)AccountKey=([A-Za-z0-9+/]{86}==)`),
+		MinEntropy:  4,
+	},
+	{
+		Name:        "azure-sas-token",
+		Description: "Azure shared access signature",
+		Pattern:     regexp.MustCompile(`[?&]sig=([A-Za-z0-9%+/]{40,}(?:%3D|=){0,2})`),
+		MinEntropy:  4,
+	},
+	{
+		Name:        "private-key",
+		Description: "Private key",
+		Pattern:     regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		Name:        "jwt",
+		Description: "JSON Web Token",
+		Pattern:     regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`),
+		MinEntropy:  3,
+	},
+	{
+		Name:        "basic-auth-url",
+		Description: "Password in URL",
+		Pattern:     regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^\s:@/$'"]+:([^\s:@/$'"]+)@`),
+		MinEntropy:  2.5,
+	},
+	{
+		Name:        "npmrc-token",
+		Description: "npm registry token",
+		Pattern:     regexp.MustCompile(`(?:_authToken|_auth|_password)\s*=\s*([A-Za-z0-9_\-.+/=]{20,})|\b(npm_[A-Za-z0-9]{36})\b`),
+		MinEntropy:  3,
+	},
+}
+
+// DefaultProviders returns the built-in providers
+func DefaultProviders() []Provider {
+	return append([]Provider{}, catalog...)
+}
+
+// DefaultProvider returns the built-in provider with the specified name
+func DefaultProvider(name string) (Provider, bool) {
+	for _, provider := range catalog {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+	return Provider{}, false
+}
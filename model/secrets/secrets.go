@@ -0,0 +1,114 @@
+// Package secrets detects hard-coded credentials within text, using a catalog of providers.
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Provider describes how to detect one kind of secret
+type Provider struct {
+	// Name identifies the provider, e.g. github-token
+	Name string
+	// Description is a human-readable name of the secret, e.g. GitHub token
+	Description string
+	// Pattern matches the secret. If the pattern has capturing groups, the first non-empty group is the secret value.
+	Pattern *regexp.Regexp
+	// MinEntropy is the minimum Shannon entropy (bits per character) of a secret value, used to skip placeholders
+	MinEntropy float64
+}
+
+// Finding is a secret found within text
+type Finding struct {
+	// Provider which found the secret
+	Provider Provider
+	// Value is the secret itself
+	Value string
+	// Start is the byte offset of Value within the scanned text
+	Start int
+	// End is the byte offset immediately following Value
+	End int
+}
+
+// Masked returns the secret value obscured by Mask
+func (f Finding) Masked() string {
+	return Mask(f.Value)
+}
+
+// Detector scans text for secrets using a set of providers
+type Detector struct {
+	providers []Provider
+}
+
+// NewDetector creates a Detector using the specified providers
+func NewDetector(providers ...Provider) *Detector {
+	return &Detector{providers: providers}
+}
+
+// Providers returns the providers used by the Detector
+func (d *Detector) Providers() []Provider {
+	return append([]Provider{}, d.providers...)
+}
+
+// Scan finds all secrets within text. Overlapping findings are reported once, by the first provider to find them.
+func (d *Detector) Scan(text string) []Finding {
+	findings := make([]Finding, 0)
+	overlaps := func(start int, end int) bool {
+		for _, finding := range findings {
+			if start < finding.End && finding.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, provider := range d.providers {
+		if provider.Pattern == nil {
+			continue
+		}
+		for _, match := range provider.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := match[0], match[1]
+			for group := 2; group+1 < len(match); group += 2 {
+				if match[group] >= 0 && match[group] < match[group+1] {
+					start, end = match[group], match[group+1]
+					break
+				}
+			}
+			value := text[start:end]
+			if value == "" || Entropy(value) < provider.MinEntropy || overlaps(start, end) {
+				continue
+			}
+			findings = append(findings, Finding{Provider: provider, Value: value, Start: start, End: end})
+		}
+	}
+	return findings
+}
+
+// Entropy calculates the Shannon entropy of value, in bits per character
+func Entropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, c := range value {
+		counts[c]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Mask obscures value, retaining at most four leading characters so the secret can be identified. The length is
+// preserved, allowing the masked value to replace the original within a line.
+func Mask(value string) string {
+	keep := len(value) / 4
+	if keep > 4 {
+		keep = 4
+	}
+	return value[:keep] + strings.Repeat("*", len(value)-keep)
+}
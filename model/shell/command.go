@@ -3,6 +3,8 @@ package shell
 import (
 	"bytes"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/jimschubert/docked/model/docker"
 	"github.com/jimschubert/docked/model/docker/commands"
@@ -10,33 +12,155 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
+// DefaultShell is the shell used by docker to interpret shell-form instructions on Linux
+var DefaultShell = []string{"/bin/sh", "-c"}
+
+// UnsupportedShellError is returned when an instruction is interpreted by a shell which can't be parsed as POSIX or bash,
+// such as PowerShell or cmd.
+type UnsupportedShellError struct {
+	Shell []string
+}
+
+func (e *UnsupportedShellError) Error() string {
+	return fmt.Sprintf("unsupported shell %q: only POSIX-compatible shells (sh, bash) can be analyzed", strings.Join(e.Shell, " "))
+}
+
 // PosixCommand is a simple representation of a command - the name of the command and any args passed to it
 type PosixCommand struct {
 	Name string
 	Args []string
+	// Pipe is the command reading the output of this command within a pipeline (curl ... | sh), or nil if the output isn't piped
+	Pipe *PosixCommand
+}
+
+// Pipeline is the command and the commands reading its output, in order (e.g. curl, tar of curl ... | tar -xz)
+func (c *PosixCommand) Pipeline() []*PosixCommand {
+	pipeline := make([]*PosixCommand, 0)
+	for next := c; next != nil; next = next.Pipe {
+		pipeline = append(pipeline, next)
+	}
+	return pipeline
 }
 
-// NewPosixCommandFromNode extracts the "command" part of a Docker instruction.
+// Variant determines the shell language to use when parsing instructions run by shell, the arguments of a SHELL instruction.
+// An empty shell is the docker default of DefaultShell. Returns UnsupportedShellError for non-POSIX shells.
+func Variant(shell []string) (syntax.LangVariant, error) {
+	if len(shell) == 0 {
+		return syntax.LangPOSIX, nil
+	}
+
+	name := interpreter(shell)
+	switch name {
+	case "sh", "ash", "dash", "busybox":
+		return syntax.LangPOSIX, nil
+	case "bash":
+		return syntax.LangBash, nil
+	default:
+		return syntax.LangPOSIX, &UnsupportedShellError{Shell: shell}
+	}
+}
+
+// interpreter finds the executable name of a shell command line, accounting for indirection like /usr/bin/env bash
+func interpreter(shell []string) string {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(shell[0], `\`, "/")))
+	if name == "env" && len(shell) > 1 {
+		return strings.ToLower(path.Base(shell[1]))
+	}
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// NewPosixCommandFromNode extracts the "command" part of a Docker instruction, assuming the DefaultShell.
 func NewPosixCommandFromNode(node *d.Node) ([]PosixCommand, error) {
-	dockerCommand, commandText := docker.Instruction(node)
+	return NewPosixCommandFromNodeWithShell(node, nil)
+}
+
+// NewPosixCommandFromNodeWithShell extracts the "command" part of a Docker instruction, interpreting shell form with
+// the arguments of the active SHELL instruction. Exec (JSON) form is converted directly without a shell.
+func NewPosixCommandFromNodeWithShell(node *d.Node, shell []string) ([]PosixCommand, error) {
+	dockerCommand, _ := docker.Instruction(node)
 	if dockerCommand != commands.Run {
 		return []PosixCommand{}, fmt.Errorf("unexpected docker command: %v", dockerCommand)
 	}
-	return NewPosixCommand(commandText)
+
+	if node.Attributes["json"] {
+		args := make([]string, 0)
+		for next := node.Next; next != nil; next = next.Next {
+			args = append(args, next.Value)
+		}
+		return NewPosixCommandFromExec(args)
+	}
+
+	var commandText string
+	if node.Next != nil {
+		commandText = node.Next.Value
+	}
+	if len(node.Heredocs) > 0 {
+		if strings.TrimLeft(commandText, "<-") == node.Heredocs[0].Name {
+			// RUN <<EOF runs the heredoc as a script
+			commandText = node.Heredocs[0].Content
+		} else {
+			buf := bytes.Buffer{}
+			buf.WriteString(commandText)
+			for _, heredoc := range node.Heredocs {
+				buf.WriteString(fmt.Sprintf("\n%s%s", heredoc.Content, heredoc.Name))
+			}
+			commandText = buf.String()
+		}
+	}
+	return NewPosixCommandWithShell(commandText, shell)
+}
+
+// NewPosixCommandFromExec converts exec (JSON) form arguments into commands. Where the executable is itself a shell
+// invoked with -c (e.g. ["/bin/bash", "-c", "apt-get update"]), the script is parsed with that shell.
+func NewPosixCommandFromExec(args []string) ([]PosixCommand, error) {
+	if len(args) == 0 {
+		return []PosixCommand{}, nil
+	}
+
+	for i := 1; i < len(args)-1; i++ {
+		if args[i] == "-c" {
+			if _, err := Variant(args[:i]); err == nil {
+				return NewPosixCommandWithShell(args[i+1], args[:i+1])
+			}
+			break
+		}
+	}
+
+	return []PosixCommand{{Name: args[0], Args: append([]string{}, args[1:]...)}}, nil
 }
 
 // NewPosixCommand parses input into an array of commands represented within that input
 func NewPosixCommand(input string) ([]PosixCommand, error) {
+	return NewPosixCommandWithShell(input, nil)
+}
+
+// NewPosixCommandWithShell parses input into an array of commands represented within that input,
+// using the language of the provided shell (the arguments of a SHELL instruction).
+func NewPosixCommandWithShell(input string, shell []string) ([]PosixCommand, error) {
+	variant, err := Variant(shell)
+	if err != nil {
+		return nil, err
+	}
+
 	parser := syntax.NewParser(syntax.KeepComments(true))
-	syntax.Variant(syntax.LangPOSIX)(parser)
+	syntax.Variant(variant)(parser)
 	parsed, err := parser.Parse(bytes.NewReader([]byte(input)), "")
 	if err != nil {
 		return nil, err
 	}
 
+	// pipes connects the last command writing to a pipe with the first command reading from it
+	pipes := make(map[*syntax.CallExpr]*syntax.CallExpr)
+	calls := make([]*syntax.CallExpr, 0)
 	commands := make([]PosixCommand, 0)
 	syntax.Walk(parsed, func(node syntax.Node) bool {
 		switch t := node.(type) {
+		case *syntax.BinaryCmd:
+			if t.Op == syntax.Pipe || t.Op == syntax.PipeAll {
+				if writer, reader := lastCall(t.X), firstCall(t.Y); writer != nil && reader != nil {
+					pipes[writer] = reader
+				}
+			}
 		case *syntax.CallExpr:
 			args := make([]string, 0)
 			command := PosixCommand{}
@@ -50,9 +174,52 @@ func NewPosixCommand(input string) ([]PosixCommand, error) {
 			command.Args = args
 
 			commands = append(commands, command)
+			calls = append(calls, t)
 		default:
 		}
 		return true
 	})
+
+	indexes := make(map[*syntax.CallExpr]int, len(calls))
+	for i, call := range calls {
+		indexes[call] = i
+	}
+	for i, call := range calls {
+		if reader, ok := pipes[call]; ok {
+			commands[i].Pipe = &commands[indexes[reader]]
+		}
+	}
 	return commands, nil
 }
+
+// firstCall finds the first command within node, excluding command substitutions
+func firstCall(node syntax.Node) *syntax.CallExpr {
+	var first *syntax.CallExpr
+	syntax.Walk(node, func(n syntax.Node) bool {
+		switch t := n.(type) {
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			return false
+		case *syntax.CallExpr:
+			if first == nil {
+				first = t
+			}
+		}
+		return first == nil
+	})
+	return first
+}
+
+// lastCall finds the last command within node, excluding command substitutions
+func lastCall(node syntax.Node) *syntax.CallExpr {
+	var last *syntax.CallExpr
+	syntax.Walk(node, func(n syntax.Node) bool {
+		switch t := n.(type) {
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			return false
+		case *syntax.CallExpr:
+			last = t
+		}
+		return true
+	})
+	return last
+}
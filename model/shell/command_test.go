@@ -2,7 +2,10 @@ package shell
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
 func TestNewPosixCommand(t *testing.T) {
@@ -61,3 +64,218 @@ func TestNewPosixCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPosixCommandWithShell(t *testing.T) {
+	type args struct {
+		input string
+		shell []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []PosixCommand
+		wantErr bool
+	}{
+		{
+			name:    "bash syntax fails in default shell",
+			args:    args{input: "diff <(echo a) <(echo b)"},
+			wantErr: true,
+		},
+		{
+			name: "bash syntax with bash shell",
+			args: args{input: "diff <(echo a) <(echo b)", shell: []string{"/bin/bash", "-o", "pipefail", "-c"}},
+			want: []PosixCommand{
+				{Name: "diff", Args: []string{"", ""}},
+				{Name: "echo", Args: []string{"a"}},
+				{Name: "echo", Args: []string{"b"}},
+			},
+		},
+		{
+			name: "env indirection",
+			args: args{input: "echo a", shell: []string{"/usr/bin/env", "sh", "-c"}},
+			want: []PosixCommand{{Name: "echo", Args: []string{"a"}}},
+		},
+		{
+			name:    "powershell is unsupported",
+			args:    args{input: "Write-Host a", shell: []string{"powershell", "-Command"}},
+			wantErr: true,
+		},
+		{
+			name:    "cmd is unsupported",
+			args:    args{input: "echo a", shell: []string{`C:\Windows\System32\cmd.exe`, "/S", "/C"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPosixCommandWithShell(tt.args.input, tt.args.shell)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPosixCommandWithShell() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewPosixCommandWithShell() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPosixCommandFromExec(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []PosixCommand
+		wantErr bool
+	}{
+		{
+			name: "executable",
+			args: []string{"apt-get", "install", "x"},
+			want: []PosixCommand{{Name: "apt-get", Args: []string{"install", "x"}}},
+		},
+		{
+			name: "shell script",
+			args: []string{"/bin/sh", "-c", "apt-get update && apt-get install x"},
+			want: []PosixCommand{
+				{Name: "apt-get", Args: []string{"update"}},
+				{Name: "apt-get", Args: []string{"install", "x"}},
+			},
+		},
+		{
+			name: "unsupported shell is an executable",
+			args: []string{"pwsh", "-c", "Write-Host a"},
+			want: []PosixCommand{{Name: "pwsh", Args: []string{"-c", "Write-Host a"}}},
+		},
+		{
+			name: "empty",
+			args: []string{},
+			want: []PosixCommand{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPosixCommandFromExec(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPosixCommandFromExec() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewPosixCommandFromExec() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPosixCommandFromNodeWithShell(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		shell   []string
+		want    []PosixCommand
+		wantErr bool
+	}{
+		{
+			name:  "flags are not commands",
+			input: "RUN --mount=type=cache,target=/var/cache/apk apk add curl",
+			want:  []PosixCommand{{Name: "apk", Args: []string{"add", "curl"}}},
+		},
+		{
+			name:  "exec form",
+			input: `RUN ["apk", "add", "curl"]`,
+			want:  []PosixCommand{{Name: "apk", Args: []string{"add", "curl"}}},
+		},
+		{
+			name:  "exec form ignores shell",
+			input: `RUN ["apk", "add", "curl"]`,
+			shell: []string{"cmd", "/S", "/C"},
+			want:  []PosixCommand{{Name: "apk", Args: []string{"add", "curl"}}},
+		},
+		{
+			name:  "heredoc script",
+			input: "RUN <<EOF\napk add curl\nrm -rf /tmp/x\nEOF",
+			want: []PosixCommand{
+				{Name: "apk", Args: []string{"add", "curl"}},
+				{Name: "rm", Args: []string{"-rf", "/tmp/x"}},
+			},
+		},
+		{
+			name:  "heredoc input",
+			input: "RUN cat <<EOT > /etc/motd\nhello\nEOT",
+			want:  []PosixCommand{{Name: "cat", Args: []string{}}},
+		},
+		{
+			name:    "unsupported shell",
+			input:   "RUN apk add curl",
+			shell:   []string{"powershell", "-Command"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unable to parse test input: %v", err)
+			}
+			got, err := NewPosixCommandFromNodeWithShell(result.AST.Children[0], tt.shell)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPosixCommandFromNodeWithShell() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewPosixCommandFromNodeWithShell() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPosixCommand_Pipeline(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]string
+	}{
+		{
+			name:  "no pipe",
+			input: "curl -fsSL -o install.sh https://example.com/install.sh && sh install.sh",
+			want:  [][]string{{"curl"}, {"sh"}},
+		},
+		{
+			name:  "pipe",
+			input: "curl -fsSL https://example.com/install.sh | sh",
+			want:  [][]string{{"curl", "sh"}, {"sh"}},
+		},
+		{
+			name:  "multiple pipes",
+			input: "wget -qO- https://example.com/app.tar.gz | tee app.tar.gz | tar -xz && rm app.tar.gz",
+			want:  [][]string{{"wget", "tee", "tar"}, {"tee", "tar"}, {"tar"}, {"rm"}},
+		},
+		{
+			name:  "command substitution",
+			input: `curl -fsSL "$(cat /tmp/url)" | sudo bash -s`,
+			want:  [][]string{{"curl", "sudo"}, {"cat"}, {"sudo"}},
+		},
+		{
+			name:  "pipe from a list",
+			input: "{ echo a; echo b; } | sort",
+			want:  [][]string{{"echo"}, {"echo", "sort"}, {"sort"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := NewPosixCommand(tt.input)
+			if err != nil {
+				t.Fatalf("NewPosixCommand() error = %v", err)
+			}
+			got := make([][]string, 0)
+			for i := range commands {
+				names := make([]string, 0)
+				for _, command := range commands[i].Pipeline() {
+					names = append(names, command.Name)
+				}
+				got = append(got, names)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pipeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
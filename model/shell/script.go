@@ -0,0 +1,118 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/docker/commands"
+	d "github.com/moby/buildkit/frontend/dockerfile/parser"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Script is the syntax tree of a shell-form instruction. Unlike PosixCommand, positions within the tree are preserved
+// and may be converted to locations within the Dockerfile.
+type Script struct {
+	File *syntax.File
+	// startLine is the 1-based line of the Dockerfile corresponding to the first line of the script
+	startLine int
+}
+
+// NewScript parses the shell form of a RUN instruction from its raw source, using the language of shell (the arguments
+// of a SHELL instruction). The instruction keyword and flags are blanked rather than removed, so columns within the
+// script match those of the Dockerfile. Returns UnsupportedShellError for non-POSIX shells, and an error for exec form.
+func NewScript(node *d.Node, source *docker.Source, shell []string) (*Script, error) {
+	dockerCommand, _ := docker.Instruction(node)
+	if dockerCommand != commands.Run {
+		return nil, fmt.Errorf("unexpected docker command: %v", dockerCommand)
+	}
+	if node.Attributes["json"] {
+		return nil, fmt.Errorf("exec form is not interpreted by a shell")
+	}
+	if source == nil || len(source.Lines) == 0 {
+		return nil, fmt.Errorf("no source for instruction at line %d", node.StartLine)
+	}
+	variant, err := Variant(shell)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := append([]string{}, source.Lines...)
+	lines[0] = blankInstruction(lines[0])
+	startLine := source.StartLine
+	if len(node.Heredocs) > 0 {
+		if name := strings.TrimSpace(lines[0]); strings.Trim(strings.TrimLeft(name, "<-"), `"'`) == node.Heredocs[0].Name {
+			// RUN <<EOF runs the heredoc as a script, which ends before the line of its terminator
+			end := len(lines)
+			for i := len(lines) - 1; i > 0; i-- {
+				if strings.TrimSpace(lines[i]) == node.Heredocs[0].Name {
+					end = i
+					break
+				}
+			}
+			lines = lines[1:end]
+			startLine++
+		}
+	} else {
+		blankComments(lines)
+	}
+
+	parser := syntax.NewParser(syntax.KeepComments(true), syntax.Variant(variant))
+	file, err := parser.Parse(strings.NewReader(strings.Join(lines, "\n")), "")
+	if err != nil {
+		return nil, err
+	}
+	return &Script{File: file, startLine: startLine}, nil
+}
+
+// blankInstruction replaces the keyword and flags (e.g. --mount=type=cache,target=/root/.cache) of an instruction's
+// first line with spaces
+func blankInstruction(line string) string {
+	blanked := []byte(line)
+	idx := 0
+	skipSpace := func() {
+		for idx < len(blanked) && (blanked[idx] == ' ' || blanked[idx] == '\t') {
+			idx++
+		}
+	}
+	skipWord := func() {
+		for idx < len(blanked) && blanked[idx] != ' ' && blanked[idx] != '\t' {
+			blanked[idx] = ' '
+			idx++
+		}
+	}
+	skipSpace()
+	skipWord()
+	for {
+		skipSpace()
+		if !strings.HasPrefix(string(blanked[idx:]), "--") {
+			break
+		}
+		skipWord()
+	}
+	return string(blanked)
+}
+
+// blankComments replaces empty and comment lines within continuation lines, which are removed by the Dockerfile parser,
+// with a continuation so the surrounding lines remain joined
+func blankComments(lines []string) {
+	for i := 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !strings.HasSuffix(strings.TrimRight(lines[i-1], " \t"), `\`) {
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			lines[i] = strings.Repeat(" ", len(lines[i])) + `\`
+		}
+	}
+}
+
+// Position converts a position within the script to a position within the Dockerfile
+func (s *Script) Position(pos syntax.Pos) docker.Position {
+	return docker.Position{Line: s.startLine + int(pos.Line()) - 1, Character: int(pos.Col()) - 1}
+}
+
+// Location determines the location of node within the Dockerfile
+func (s *Script) Location(node syntax.Node) docker.Location {
+	return docker.Location{Start: s.Position(node.Pos()), End: s.Position(node.End())}
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestNewScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		shell   []string
+		command string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "first line",
+			input:   "FROM alpine\nRUN apk add curl && curl --fail https://example.com",
+			command: "curl",
+			want:    "2:20",
+		},
+		{
+			name:    "flags",
+			input:   "FROM alpine\nRUN --mount=type=cache,target=/var/cache/apk apk add curl",
+			command: "apk",
+			want:    "2:45",
+		},
+		{
+			name:    "continuation lines",
+			input:   "FROM alpine\nRUN apk add curl \\\n    && cd /tmp \\\n    && make",
+			command: "cd",
+			want:    "3:7",
+		},
+		{
+			name:    "comments within continuation lines",
+			input:   "FROM alpine\nRUN apk add curl \\\n# install the tools\n\n    && make",
+			command: "make",
+			want:    "5:7",
+		},
+		{
+			name:    "heredoc script",
+			input:   "FROM alpine\nRUN <<EOF\nset -e\n  make install\nEOF",
+			command: "make",
+			want:    "4:2",
+		},
+		{
+			name:    "heredoc input",
+			input:   "FROM alpine\nRUN cat <<EOT > /etc/motd\nhello\nEOT",
+			command: "cat",
+			want:    "2:4",
+		},
+		{
+			name:    "bash",
+			input:   "FROM alpine\nRUN [[ -f /etc/motd ]] && rm /etc/motd",
+			shell:   []string{"/bin/bash", "-c"},
+			command: "rm",
+			want:    "2:26",
+		},
+		{
+			name:    "exec form",
+			input:   `FROM alpine` + "\n" + `RUN ["apk", "add", "curl"]`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported shell",
+			input:   "FROM alpine\nRUN apk add curl",
+			shell:   []string{"powershell", "-Command"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parser.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unable to parse test input: %v", err)
+			}
+			node := result.AST.Children[1]
+			got, err := NewScript(node, docker.NewSource(strings.Split(tt.input, "\n"), node), tt.shell)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var location *docker.Location
+			syntax.Walk(got.File, func(n syntax.Node) bool {
+				if call, ok := n.(*syntax.CallExpr); ok && location == nil && len(call.Args) > 0 && call.Args[0].Lit() == tt.command {
+					l := got.Location(call)
+					location = &l
+				}
+				return true
+			})
+			if location == nil {
+				t.Fatalf("NewScript() missing command %s", tt.command)
+			}
+			if fmt.Sprintf("%d:%d", location.Start.Line, location.Start.Character) != tt.want {
+				t.Errorf("Location() = %v, want %s", location.Start, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package validations
 
 import (
+	"strings"
+
 	"github.com/jimschubert/docked/model"
 	"github.com/jimschubert/docked/model/docker/commands"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
@@ -14,6 +16,7 @@ type MultiContextRule struct {
 	Priority         model.Priority           `json:"priority,omitempty"`
 	Commands         []commands.DockerCommand `json:"commands,omitempty"`
 	AppliesToBuilder bool                     `json:"applies_to_builder,omitempty"`
+	AppliesToOnbuild bool                     `json:"applies_to_onbuild,omitempty"`
 	Category         *string                  `json:"category,omitempty"`
 	URL              *string                  `json:"url,omitempty"`
 	Evaluator        MultiContextEvaluator    `json:"-"`
@@ -67,6 +70,9 @@ func (m *MultiContextRule) GetLintID() string {
 
 // Evaluate a parsed node and its context
 func (m *MultiContextRule) Evaluate(node *parser.Node, validationContext ValidationContext) *ValidationResult {
+	if validationContext.IsOnbuildTrigger && !m.AppliesToOnbuild {
+		return nil
+	}
 	if validationContext.IsBuilderContext && m.AppliesToBuilder {
 		*m.ContextCache = append(*m.ContextCache, NodeValidationContext{Node: *node, Context: validationContext})
 	}
@@ -122,14 +128,28 @@ func (m MultiContextFullEvaluator) Evaluate(mcr *MultiContextRule) *ValidationRe
 type MultiContextPerNodeEvaluator struct {
 	// Fn evaluates a parser.Node and its associated ValidationContext to determine if the context is valid
 	Fn func(node *parser.Node, validationContext ValidationContext) model.Valid
+	// Unevaluated optionally explains why a node for which Fn returns model.Skipped couldn't be evaluated, or returns an empty
+	// string when the node doesn't apply. Unless another node fails or has recommendations, the result is then model.Skipped
+	// with the explanations rather than model.Success.
+	Unevaluated func(node *parser.Node, validationContext ValidationContext) string
 }
 
 // Evaluate a given MultiContextRule to determine the final ValidationResult
 func (m MultiContextPerNodeEvaluator) Evaluate(mcr *MultiContextRule) *ValidationResult {
 	result := model.Success
 	validationContexts := make([]ValidationContext, 0)
+	reasons := make([]string, 0)
+	unevaluatedContexts := make([]ValidationContext, 0)
 	for _, nodeContext := range *mcr.ContextCache {
 		state := m.Fn(&nodeContext.Node, nodeContext.Context)
+		if state == model.Skipped && m.Unevaluated != nil {
+			if reason := m.Unevaluated(&nodeContext.Node, nodeContext.Context); reason != "" {
+				if !model.StringSliceContains(&reasons, reason) {
+					reasons = append(reasons, reason)
+				}
+				unevaluatedContexts = append(unevaluatedContexts, nodeContext.Context)
+			}
+		}
 		if state == model.Failure {
 			nodeContext.Context.CausedFailure = true
 			result = model.Failure
@@ -140,6 +160,13 @@ func (m MultiContextPerNodeEvaluator) Evaluate(mcr *MultiContextRule) *Validatio
 		}
 		validationContexts = append(validationContexts, nodeContext.Context)
 	}
+	if result == model.Success && len(reasons) > 0 {
+		return &ValidationResult{
+			Result:   model.Skipped,
+			Details:  strings.Join(reasons, "; "),
+			Contexts: unevaluatedContexts,
+		}
+	}
 	return &ValidationResult{
 		Result:   result,
 		Details:  mcr.GetSummary(),
@@ -17,6 +17,7 @@ type SimpleDeferredRegexRule struct {
 	Priority         model.Priority           `json:"priority,omitempty"`
 	Commands         []commands.DockerCommand `json:"commands,omitempty"`
 	AppliesToBuilder bool                     `json:"applies_to_builder,omitempty"`
+	AppliesToOnbuild bool                     `json:"applies_to_onbuild,omitempty"`
 	Category         *string                  `json:"category,omitempty"`
 	URL              *string                  `json:"url,omitempty"`
 	inBuilderImage   bool
@@ -70,6 +71,9 @@ func (r *SimpleDeferredRegexRule) GetLintID() string {
 
 // Evaluate a parsed node and its context
 func (r *SimpleDeferredRegexRule) Evaluate(node *parser.Node, validationContext ValidationContext) *ValidationResult {
+	if validationContext.IsOnbuildTrigger && !r.AppliesToOnbuild {
+		return nil
+	}
 	if validationContext.IsBuilderContext && r.AppliesToBuilder {
 		*r.contextCache = append(*r.contextCache, NodeValidationContext{Node: *node, Context: validationContext})
 	}
@@ -11,15 +11,16 @@ import (
 
 // SimpleRegexRule is a no-frills regex evaluation which occurs for each relevant docker node.
 type SimpleRegexRule struct {
-	Name      string                 `json:"name,omitempty"`
-	Summary   string                 `json:"summary,omitempty"`
-	Details   string                 `json:"details,omitempty"`
-	Pattern   string                 `json:"pattern,omitempty"`
-	Priority  model.Priority         `json:"priority,omitempty"`
-	Command   commands.DockerCommand `json:"command,omitempty"`
-	Category  *string                `json:"category,omitempty"`
-	URL       *string                `json:"url,omitempty"`
-	_commands []commands.DockerCommand
+	Name             string                 `json:"name,omitempty"`
+	Summary          string                 `json:"summary,omitempty"`
+	Details          string                 `json:"details,omitempty"`
+	Pattern          string                 `json:"pattern,omitempty"`
+	Priority         model.Priority         `json:"priority,omitempty"`
+	Command          commands.DockerCommand `json:"command,omitempty"`
+	Category         *string                `json:"category,omitempty"`
+	URL              *string                `json:"url,omitempty"`
+	AppliesToOnbuild bool                   `json:"applies_to_onbuild,omitempty"`
+	_commands        []commands.DockerCommand
 }
 
 // GetName gets the name of the rule
@@ -73,6 +74,9 @@ func (r SimpleRegexRule) GetLintID() string {
 
 // Evaluate a parsed node and its context
 func (r SimpleRegexRule) Evaluate(node *parser.Node, validationContext ValidationContext) *ValidationResult {
+	if validationContext.IsOnbuildTrigger && !r.AppliesToOnbuild {
+		return nil
+	}
 	_, matchAgainst := docker.Instruction(node)
 	if model.NewPattern(r.Pattern).Matches(matchAgainst) {
 		validationContext.CausedFailure = true
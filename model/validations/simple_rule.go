@@ -11,14 +11,15 @@ type handlerFunc func(node *parser.Node, validationContext ValidationContext) *V
 
 // SimpleRule is the simplest implementation of a rule
 type SimpleRule struct {
-	Name     string                   `json:"name,omitempty"`
-	Summary  string                   `json:"summary,omitempty"`
-	Details  string                   `json:"details,omitempty"`
-	Priority model.Priority           `json:"priority,omitempty"`
-	Commands []commands.DockerCommand `json:"commands,omitempty"`
-	Handler  handlerFunc              `json:"-"`
-	Category *string                  `json:"category,omitempty"`
-	URL      *string                  `json:"url,omitempty"`
+	Name             string                   `json:"name,omitempty"`
+	Summary          string                   `json:"summary,omitempty"`
+	Details          string                   `json:"details,omitempty"`
+	Priority         model.Priority           `json:"priority,omitempty"`
+	Commands         []commands.DockerCommand `json:"commands,omitempty"`
+	Handler          handlerFunc              `json:"-"`
+	Category         *string                  `json:"category,omitempty"`
+	URL              *string                  `json:"url,omitempty"`
+	AppliesToOnbuild bool                     `json:"applies_to_onbuild,omitempty"`
 }
 
 // GetName gets the name of the rule
@@ -63,5 +64,8 @@ func (r SimpleRule) GetLintID() string {
 
 // Evaluate a parsed node and its context
 func (r SimpleRule) Evaluate(node *parser.Node, validationContext ValidationContext) *ValidationResult {
+	if validationContext.IsOnbuildTrigger && !r.AppliesToOnbuild {
+		return nil
+	}
 	return r.Handler(node, validationContext)
 }
@@ -20,6 +20,18 @@ type ValidationContext struct {
 	CausedFailure      bool              `json:"caused_failure,omitempty"`      // Whether the parsed Line caused a failure in the final Validation
 	HasRecommendations bool              `json:"has_recommendations,omitempty"` // Whether the parsed Line includes a recommendation in the final Validation
 	IsBuilderContext   bool              `json:"is_builder_context,omitempty"`  // Whether the context is a "builder" context of a multi-stage build
+	Shell              []string          `json:"shell,omitempty"`               // The arguments of the SHELL instruction active in the build stage, if any
+	IsOnbuildTrigger   bool              `json:"is_onbuild_trigger,omitempty"`  // Whether the instruction is the trigger of an ONBUILD instruction, run by downstream builds
+	Sensitive          bool              `json:"sensitive,omitempty"`           // Whether the Locations hold a secret, which reporters must mask
+
+	Instruction  *docker.ParsedInstruction `json:"-"` // The typed instruction, parsed once per node
+	Stage        *docker.Stage             `json:"-"` // The build stage holding the instruction, or nil for instructions before the first FROM
+	Variables    docker.Variables          `json:"-"` // The values of ARG and ENV in effect before the instruction, within its build stage
+	Directives   *docker.Directives        `json:"-"` // The parser directives of the Dockerfile
+	Source       *docker.Source            `json:"-"` // The raw text of the instruction, for locating text within it
+	BuildContext *docker.BuildContext      `json:"-"` // The build context directory, or nil when not known
+	DockerIgnore *docker.DockerIgnore      `json:"-"` // The ignore file applied to the build context, or nil when none exists
+	Settings     any                       `json:"-"` // The settings of the rules configured for the analysis (*rules.Settings), or nil for the defaults
 }
 
 // NodeValidationContext associates a parser.Node and ValidationContext, such as deferred execution via rules implementing FinalizingRule.
@@ -15,6 +15,8 @@ import (
 
 	"github.com/jimschubert/docked"
 	"github.com/jimschubert/docked/model"
+	"github.com/jimschubert/docked/model/docker"
+	"github.com/jimschubert/docked/model/secrets"
 	"github.com/jimschubert/docked/model/validations"
 	"github.com/sirupsen/logrus"
 )
@@ -69,7 +71,7 @@ func (h *HTMLReporter) Write(result docked.AnalysisResult) error {
 		}
 	}(file)
 
-	rows := h.initializeRows(file)
+	rows := h.initializeRows(file, h.sensitiveLocations(result))
 	errorCount := h.fillErrors(result, rows)
 	_ = h.fillRecommendations(result, rows)
 
@@ -183,7 +185,37 @@ func (h *HTMLReporter) fillErrors(result docked.AnalysisResult, rows []*htmlRow)
 	return errorCount
 }
 
-func (h *HTMLReporter) initializeRows(file *os.File) []*htmlRow {
+// sensitiveLocations collects the locations of secrets, by line number, which must be masked in the output
+func (h *HTMLReporter) sensitiveLocations(result docked.AnalysisResult) map[int][]docker.Location {
+	sensitive := make(map[int][]docker.Location)
+	for _, validation := range result.Evaluated {
+		for _, ctx := range validation.ValidationResult.Contexts {
+			if !ctx.Sensitive {
+				continue
+			}
+			for _, location := range ctx.Locations {
+				if location.Start.Line == location.End.Line {
+					sensitive[location.Start.Line] = append(sensitive[location.Start.Line], location)
+				}
+			}
+		}
+	}
+	return sensitive
+}
+
+// maskLine replaces the characters of line within each of locations with the masked secret
+func (h *HTMLReporter) maskLine(line string, locations []docker.Location) string {
+	for _, location := range locations {
+		start, end := location.Start.Character, location.End.Character
+		if start < 0 || end > len(line) || start >= end {
+			continue
+		}
+		line = line[:start] + secrets.Mask(line[start:end]) + line[end:]
+	}
+	return line
+}
+
+func (h *HTMLReporter) initializeRows(file *os.File, sensitive map[int][]docker.Location) []*htmlRow {
 	rows := make([]*htmlRow, 0)
 	// We can't use docker's buildkit parser here because it removes newlines/continuations within commands.
 	// We need file formatting fidelity, so we need to work out some naive row parsing here.
@@ -192,7 +224,7 @@ func (h *HTMLReporter) initializeRows(file *os.File) []*htmlRow {
 	var row *htmlRow
 	for scanner.Scan() {
 		line++
-		lineContent := scanner.Text()
+		lineContent := h.maskLine(scanner.Text(), sensitive[line])
 		if line == 1 {
 			row = &htmlRow{Contents: lineContent, RowNumber: line, LineStart: line, LineEnd: line}
 			rows = append(rows, row)
@@ -18,6 +18,7 @@ var (
 	brightRed   = color.New(color.FgRed, color.Bold)
 	brightGreen = color.New(color.FgGreen, color.Bold)
 	cyan        = color.New(color.FgCyan, color.Bold)
+	yellow      = color.New(color.FgYellow, color.Bold)
 )
 
 // TextReporter writes formatted output in textual column format to Out.
@@ -79,6 +80,9 @@ func (t *TextReporter) writeValidationLine(tt *tabitha.Writer, v validations.Val
 	if v.ValidationResult.Result == model.Recommendation {
 		indicator = cyan.Sprint("R")
 	}
+	if v.ValidationResult.Result == model.Skipped {
+		indicator = yellow.Sprint("-")
+	}
 	r := *v.Rule
 	priority := strings.TrimSuffix(r.GetPriority().String(), "Priority")
 